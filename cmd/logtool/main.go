@@ -0,0 +1,96 @@
+// Command logtool has operator utilities for logs written by go-logger-facade.
+//
+// Usage:
+//
+//	logtool pseudonym -key-id ID (-key-file FILE | -key-env VAR) IDENTIFIER...
+//
+// pseudonym prints the pseudonym a logger.Pseudonym field of each identifier is written as so logs can be searched
+// for it. The key is the one passed to Logger.SetPseudonymKey, read from a file or an environment variable so it does
+// not show up in the process list. A trailing newline in the key file is ignored.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/davidwartell/go-logger-facade/logger"
+	"io"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "pseudonym":
+		err = pseudonym(os.Args[2:], os.Stdout)
+	case "-h", "-help", "--help", "help":
+		usage()
+		return
+	default:
+		_, _ = fmt.Fprintf(os.Stderr, "logtool: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "logtool: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	_, _ = fmt.Fprintln(os.Stderr, "usage: logtool pseudonym -key-id ID (-key-file FILE | -key-env VAR) IDENTIFIER...")
+}
+
+// pseudonym writes a line with each identifier and its pseudonym to out.
+func pseudonym(args []string, out io.Writer) (err error) {
+	fs := flag.NewFlagSet("pseudonym", flag.ContinueOnError)
+	keyID := fs.String("key-id", "", "id the key was set with, the prefix of the pseudonyms to search for")
+	keyFile := fs.String("key-file", "", "file containing the key")
+	keyEnv := fs.String("key-env", "", "environment variable containing the key")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if *keyID == "" {
+		return errors.New("pseudonym: -key-id is required")
+	}
+	if fs.NArg() == 0 {
+		return errors.New("pseudonym: no identifiers")
+	}
+
+	var key []byte
+	switch {
+	case *keyFile != "" && *keyEnv != "":
+		return errors.New("pseudonym: only one of -key-file and -key-env can be set")
+	case *keyFile != "":
+		if key, err = os.ReadFile(*keyFile); err != nil {
+			return
+		}
+		key = bytes.TrimSuffix(bytes.TrimSuffix(key, []byte("\n")), []byte("\r"))
+	case *keyEnv != "":
+		value, found := os.LookupEnv(*keyEnv)
+		if !found {
+			return fmt.Errorf("pseudonym: environment variable %s is not set", *keyEnv)
+		}
+		key = []byte(value)
+	default:
+		return errors.New("pseudonym: -key-file or -key-env is required")
+	}
+	if len(key) == 0 {
+		return errors.New("pseudonym: key is empty")
+	}
+
+	for _, identifier := range fs.Args() {
+		if _, err = fmt.Fprintf(out, "%s\t%s\n", identifier, logger.DerivePseudonym(*keyID, key, identifier)); err != nil {
+			return
+		}
+	}
+	return
+}
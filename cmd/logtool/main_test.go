@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const alicePseudonym = "k1:1fd60690b6512633d366897ea0aa4b58"
+
+func TestPseudonymKeyFile(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	// the trailing newline of an editor or echo is not part of the key
+	if err := os.WriteFile(keyFile, []byte("sekrit\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := pseudonym([]string{"-key-id", "k1", "-key-file", keyFile, "alice", "bob"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2 || lines[0] != "alice\t"+alicePseudonym || !strings.HasPrefix(lines[1], "bob\tk1:") {
+		t.Fatalf("output = %q", out.String())
+	}
+}
+
+func TestPseudonymKeyEnv(t *testing.T) {
+	t.Setenv("LOGTOOL_TEST_KEY", "sekrit")
+	var out bytes.Buffer
+	if err := pseudonym([]string{"-key-id", "k1", "-key-env", "LOGTOOL_TEST_KEY", "alice"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "alice\t"+alicePseudonym+"\n" {
+		t.Fatalf("output = %q", out.String())
+	}
+}
+
+func TestPseudonymErrors(t *testing.T) {
+	t.Setenv("LOGTOOL_TEST_EMPTY", "")
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("sekrit"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string][]string{
+		"no key id":      {"-key-file", keyFile, "alice"},
+		"no identifiers": {"-key-id", "k1", "-key-file", keyFile},
+		"no key":         {"-key-id", "k1", "alice"},
+		"two keys":       {"-key-id", "k1", "-key-file", keyFile, "-key-env", "LOGTOOL_TEST_EMPTY", "alice"},
+		"unset env":      {"-key-id", "k1", "-key-env", "LOGTOOL_TEST_UNSET", "alice"},
+		"empty key":      {"-key-id", "k1", "-key-env", "LOGTOOL_TEST_EMPTY", "alice"},
+		"missing file":   {"-key-id", "k1", "-key-file", keyFile + ".missing", "alice"},
+	}
+	for name, args := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := pseudonym(args, &out); err == nil {
+				t.Fatal("no error")
+			}
+			if out.Len() != 0 {
+				t.Fatalf("output on error: %q", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package logger
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAsyncQueueSize      = 4096
+	defaultAsyncReportInterval = 10 * time.Second
+	defaultAsyncDrainTimeout   = 5 * time.Second
+)
+
+// AsyncPolicy decides what happens to an entry logged while an async LogInstance's queue is full.
+type AsyncPolicy int
+
+//goland:noinspection GoUnusedConst
+const (
+	// AsyncBlock waits for room in the queue.
+	AsyncBlock AsyncPolicy = iota
+	// AsyncDropNewest drops the entry being logged.
+	AsyncDropNewest
+	// AsyncDropOldest drops the oldest queued entry to make room.
+	AsyncDropOldest
+	// AsyncDropBelowLevel drops the entry being logged if it is below AsyncOptions.DropBelowLevel and waits for room
+	// otherwise.
+	AsyncDropBelowLevel
+)
+
+// AsyncOptions configures asynchronous dispatch for a LogInstance.
+type AsyncOptions struct {
+	// QueueSize is the number of entries buffered between the caller and the writer. Defaults to 4096.
+	QueueSize int
+	// Policy is applied when the queue is full. Defaults to AsyncBlock.
+	Policy AsyncPolicy
+	// DropBelowLevel is used by AsyncDropBelowLevel.
+	DropBelowLevel Level
+	// ReportInterval is how often a warning with the number of dropped entries is written to the instance. Defaults
+	// to 10 seconds.
+	ReportInterval time.Duration
+	// DrainTimeout bounds how long Sync (and StopTask) waits for the queue to drain. Defaults to 5 seconds.
+	DrainTimeout time.Duration
+}
+
+// WithAsync makes a LogInstance added with AddLogger (or one of the network sinks) write entries from a background
+// goroutine so a slow writer does not stall the caller. Entries still queued are drained by Sync, StopTask and
+// RemoveLogger. Object, array, reflected and Stringer fields are encoded on the caller so values changed after
+// logging are written as they were logged.
+// example: logger.Instance().AddLogger("slow", w, logger.InfoLevel, logger.WithAsync(logger.AsyncOptions{Policy: logger.AsyncDropOldest}))
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithAsync(asyncOptions AsyncOptions) LoggingOption {
+	return func(o *Options) {
+		o.asyncOptions = asyncOptions
+		o.asyncEnabled = true
+	}
+}
+
+// asyncItem is a queued entry, or a drain marker when done is set.
+type asyncItem struct {
+	core   zapcore.Core
+	ent    zapcore.Entry
+	fields []zapcore.Field
+	done   chan struct{}
+}
+
+// asyncQueue is shared by an asyncCore and the cores derived from it with With.
+type asyncQueue struct {
+	opts      AsyncOptions
+	items     chan asyncItem
+	metrics   *instanceMetrics
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// asyncCore queues entries for the wrapped core and writes them from a background goroutine.
+type asyncCore struct {
+	zapcore.Core
+	queue *asyncQueue
+}
+
+// newAsyncCore wraps core and starts its writer. closeQueue drains the queue for up to DrainTimeout and stops the
+// writer, entries logged after it are dropped.
+func newAsyncCore(core zapcore.Core, asyncOpts AsyncOptions, metrics *instanceMetrics) (wrapped zapcore.Core, closeQueue func() error) {
+	if asyncOpts.QueueSize <= 0 {
+		asyncOpts.QueueSize = defaultAsyncQueueSize
+	}
+	if asyncOpts.ReportInterval <= 0 {
+		asyncOpts.ReportInterval = defaultAsyncReportInterval
+	}
+	if asyncOpts.DrainTimeout <= 0 {
+		asyncOpts.DrainTimeout = defaultAsyncDrainTimeout
+	}
+	queue := &asyncQueue{
+		opts:    asyncOpts,
+		items:   make(chan asyncItem, asyncOpts.QueueSize),
+		metrics: metrics,
+		done:    make(chan struct{}),
+	}
+	go queue.run()
+	go queue.report(core)
+	wrapped = &asyncCore{
+		Core:  core,
+		queue: queue,
+	}
+	closeQueue = queue.close
+	return
+}
+
+func (c *asyncCore) With(fields []zapcore.Field) zapcore.Core {
+	return &asyncCore{
+		Core:  c.Core.With(fields),
+		queue: c.queue,
+	}
+}
+
+func (c *asyncCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *asyncCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	item := asyncItem{
+		core:   c.Core,
+		ent:    ent,
+		fields: make([]zapcore.Field, len(fields)),
+	}
+	for i, field := range fields {
+		item.fields[i] = snapshotField(field)
+	}
+	c.queue.enqueue(item)
+	return nil
+}
+
+// Sync waits up to DrainTimeout for the entries queued so far to be written and then syncs the wrapped core.
+func (c *asyncCore) Sync() error {
+	c.queue.drain()
+	return c.Core.Sync()
+}
+
+// snapshotField encodes fields whose value is only read when the entry is encoded, so the writer goroutine does not
+// read values the caller may have changed since.
+func snapshotField(field zapcore.Field) zapcore.Field {
+	switch field.Type {
+	case zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType, zapcore.ReflectType, zapcore.StringerType,
+		zapcore.InlineMarshalerType:
+	default:
+		return field
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	field.AddTo(enc)
+	values := snapshotValue(enc.Fields).(map[string]interface{})
+	if field.Type != zapcore.InlineMarshalerType && len(values) == 1 {
+		if value, found := values[field.Key]; found {
+			if object, isObject := value.(map[string]interface{}); isObject {
+				return zap.Object(field.Key, mapObject(object))
+			}
+			return zap.Any(field.Key, value)
+		}
+	}
+	// inline fields, and fields that failed to encode and added an error key
+	return zapcore.Field{Key: field.Key, Type: zapcore.InlineMarshalerType, Interface: mapObject(values)}
+}
+
+// snapshotValue deep copies a value added to a map encoder. Reflected values are converted to their JSON form.
+func snapshotValue(value interface{}) interface{} {
+	switch val := value.(type) {
+	case nil, time.Time, time.Duration:
+		return val
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(val))
+		for key, elem := range val {
+			copied[key] = snapshotValue(elem)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(val))
+		for i, elem := range val {
+			copied[i] = snapshotValue(elem)
+		}
+		return copied
+	case []byte:
+		return append([]byte(nil), val...)
+	}
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32,
+		reflect.Float64, reflect.Complex64, reflect.Complex128, reflect.String:
+		return value
+	}
+	return jsonRoundTrip(value)
+}
+
+// drain waits up to DrainTimeout for the entries queued so far to be written.
+func (q *asyncQueue) drain() {
+	done := make(chan struct{})
+	timer := time.NewTimer(q.opts.DrainTimeout)
+	defer timer.Stop()
+	select {
+	case q.items <- asyncItem{done: done}:
+		select {
+		case <-done:
+		case <-timer.C:
+		}
+	case <-timer.C:
+	case <-q.done:
+	}
+}
+
+// close drains the queue and stops the writer and reporter.
+func (q *asyncQueue) close() error {
+	q.closeOnce.Do(func() {
+		q.drain()
+		close(q.done)
+	})
+	return nil
+}
+
+func (q *asyncQueue) enqueue(item asyncItem) {
+	select {
+	case <-q.done:
+		// the instance was closed
+		q.metrics.dropped.Inc()
+		return
+	case q.items <- item:
+		return
+	default:
+	}
+
+	// queue is full
+	switch q.opts.Policy {
+	case AsyncDropNewest:
+		q.metrics.dropped.Inc()
+		return
+	case AsyncDropOldest:
+		for {
+			select {
+			case q.items <- item:
+				return
+			case <-q.done:
+				q.metrics.dropped.Inc()
+				return
+			default:
+			}
+			select {
+			case oldest := <-q.items:
+				if oldest.done != nil {
+					// never drop a drain marker, the writer is behind so Sync times out anyway
+					close(oldest.done)
+				} else {
+					q.metrics.dropped.Inc()
+				}
+			default:
+			}
+		}
+	case AsyncDropBelowLevel:
+		if item.ent.Level < zapcore.Level(q.opts.DropBelowLevel) {
+			q.metrics.dropped.Inc()
+			return
+		}
+	}
+	select {
+	case q.items <- item:
+	case <-q.done:
+		q.metrics.dropped.Inc()
+	}
+}
+
+func (q *asyncQueue) run() {
+	for {
+		select {
+		case item := <-q.items:
+			if item.done != nil {
+				close(item.done)
+				continue
+			}
+			_ = item.core.Write(item.ent, item.fields)
+		case <-q.done:
+			return
+		}
+	}
+}
+
+// report periodically writes a warning with the number of entries dropped since the last report.
+func (q *asyncQueue) report(core zapcore.Core) {
+	ticker := time.NewTicker(q.opts.ReportInterval)
+	defer ticker.Stop()
+	var reported uint64
+	for {
+		select {
+		case <-ticker.C:
+		case <-q.done:
+			return
+		}
+		dropped := q.metrics.dropped.Load()
+		if dropped == reported {
+			continue
+		}
+		ent := zapcore.Entry{
+			Level:   zapcore.WarnLevel,
+			Time:    time.Now(),
+			Message: "async log queue full entries dropped",
+		}
+		select {
+		case q.items <- asyncItem{
+			core:   core,
+			ent:    ent,
+			fields: []zapcore.Field{zapcore.Field(Uint64("dropped", dropped-reported))},
+		}:
+			reported = dropped
+		default:
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package logger
+
+import (
+	"go.uber.org/zap/zapcore"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gateWriter blocks writes until open is closed.
+type gateWriter struct {
+	open chan struct{}
+	buf  *syncBuffer
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	<-w.open
+	return w.buf.Write(p)
+}
+
+type mutableObject struct {
+	mutex sync.Mutex
+	value string
+}
+
+func (o *mutableObject) set(value string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.value = value
+}
+
+func (o *mutableObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	enc.AddString("value", o.value)
+	return nil
+}
+
+func (o *mutableObject) String() string {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.value
+}
+
+func TestAsyncSnapshotsFields(t *testing.T) {
+	s := NewLogger()
+	s.StartTask()
+	t.Cleanup(s.StopTask)
+	writer := &gateWriter{open: make(chan struct{}), buf: new(syncBuffer)}
+	s.AddLogger("async", writer, InfoLevel, WithAsync(AsyncOptions{}))
+
+	object := &mutableObject{value: "logged"}
+	reflected := map[string]interface{}{"value": "logged"}
+	s.Info("entry", Object("object", object), Stringer("stringer", object), Reflect("reflected", reflected), Group("", Object("inline", object)))
+	object.set("changed")
+	reflected["value"] = "changed"
+	close(writer.open)
+	s.Sync()
+
+	entry := lastEntry(t, writer.buf)
+	if entry["object"].(map[string]interface{})["value"] != "logged" ||
+		entry["stringer"] != "logged" ||
+		entry["reflected"].(map[string]interface{})["value"] != "logged" ||
+		entry["inline"].(map[string]interface{})["value"] != "logged" {
+		t.Fatalf("fields were read after logging: %s", writer.buf.String())
+	}
+}
+
+func TestAsyncCloseStopsWriter(t *testing.T) {
+	before := runtime.NumGoroutine()
+	buf := new(syncBuffer)
+	inner := zapcore.NewCore(zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg"}), zapcore.AddSync(buf), zapcore.InfoLevel)
+	core, closeQueue := newAsyncCore(inner, AsyncOptions{QueueSize: 1}, new(instanceMetrics))
+	if err := core.Write(zapcore.Entry{Message: "before"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := closeQueue(); err != nil {
+		t.Fatal(err)
+	}
+	if lines := buf.lines(); len(lines) != 1 {
+		t.Fatalf("queued entry not drained on close: %q", lines)
+	}
+
+	// with the writer stopped a blocking queue must not block the caller
+	written := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			_ = core.Write(zapcore.Entry{Message: "after"}, nil)
+		}
+		close(written)
+	}()
+	select {
+	case <-written:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Write blocked after close")
+	}
+	waitForGoroutines(t, before)
+}
+
+func TestRemoveLoggerStopsAsyncWriter(t *testing.T) {
+	s, _ := newTestLogger(t, InfoLevel)
+	before := runtime.NumGoroutine()
+	buf := new(syncBuffer)
+	s.AddLogger("async", buf, InfoLevel, WithAsync(AsyncOptions{}))
+	s.Info("entry")
+	s.RemoveLogger("async")
+	if lines := buf.lines(); len(lines) != 1 {
+		t.Fatalf("queued entry not drained on remove: %q", lines)
+	}
+	waitForGoroutines(t, before)
+}
+
+// newGatedAsyncCore returns an async core writing JSON to a gateWriter, with its writer goroutine blocked writing a
+// first entry so the queue of QueueSize 1 is full after one more entry.
+func newGatedAsyncCore(t *testing.T, asyncOpts AsyncOptions) (*asyncCore, *gateWriter, *instanceMetrics) {
+	t.Helper()
+	writer := &gateWriter{open: make(chan struct{}), buf: new(syncBuffer)}
+	inner := zapcore.NewCore(zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg"}), zapcore.AddSync(writer), zapcore.DebugLevel)
+	metrics := new(instanceMetrics)
+	asyncOpts.QueueSize = 1
+	wrapped, closeQueue := newAsyncCore(inner, asyncOpts, metrics)
+	t.Cleanup(func() {
+		_ = closeQueue()
+	})
+	core := wrapped.(*asyncCore)
+
+	_ = core.Write(zapcore.Entry{Level: zapcore.InfoLevel, Message: "first"}, nil)
+	deadline := time.Now().Add(2 * time.Second)
+	for len(core.queue.items) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("writer did not take the first entry")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	_ = core.Write(zapcore.Entry{Level: zapcore.InfoLevel, Message: "second"}, nil)
+	return core, writer, metrics
+}
+
+// writeAsync writes an entry from a goroutine because it may block, and returns a channel closed when it returns.
+func writeAsync(core zapcore.Core, level zapcore.Level, msg string) chan struct{} {
+	written := make(chan struct{})
+	go func() {
+		_ = core.Write(zapcore.Entry{Level: level, Message: msg}, nil)
+		close(written)
+	}()
+	return written
+}
+
+func asyncMessages(t *testing.T, buf *syncBuffer) (messages []string) {
+	t.Helper()
+	for _, entry := range buf.entries(t) {
+		messages = append(messages, entry["msg"].(string))
+	}
+	return
+}
+
+func TestAsyncOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    AsyncOptions
+		level   zapcore.Level
+		blocks  bool
+		want    []string
+		dropped uint64
+	}{
+		{name: "drop newest", opts: AsyncOptions{Policy: AsyncDropNewest}, level: zapcore.ErrorLevel, want: []string{"first", "second"}, dropped: 1},
+		{name: "drop oldest", opts: AsyncOptions{Policy: AsyncDropOldest}, level: zapcore.ErrorLevel, want: []string{"first", "third"}, dropped: 1},
+		{name: "drop below level", opts: AsyncOptions{Policy: AsyncDropBelowLevel, DropBelowLevel: WarnLevel}, level: zapcore.InfoLevel, want: []string{"first", "second"}, dropped: 1},
+		{name: "keep at level", opts: AsyncOptions{Policy: AsyncDropBelowLevel, DropBelowLevel: WarnLevel}, level: zapcore.WarnLevel, blocks: true, want: []string{"first", "second", "third"}},
+		{name: "block", opts: AsyncOptions{Policy: AsyncBlock}, level: zapcore.DebugLevel, blocks: true, want: []string{"first", "second", "third"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, writer, metrics := newGatedAsyncCore(t, tt.opts)
+			written := writeAsync(core, tt.level, "third")
+			select {
+			case <-written:
+				if tt.blocks {
+					t.Fatal("Write returned while the queue was full")
+				}
+			case <-time.After(50 * time.Millisecond):
+				if !tt.blocks {
+					t.Fatal("Write blocked while the queue was full")
+				}
+			}
+			close(writer.open)
+			<-written
+			_ = core.Sync()
+
+			if got := asyncMessages(t, writer.buf); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("messages = %q, want %q", got, tt.want)
+			}
+			if dropped := metrics.snapshot().Dropped; dropped != tt.dropped {
+				t.Fatalf("dropped = %d, want %d", dropped, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestAsyncReportsDropped(t *testing.T) {
+	core, writer, _ := newGatedAsyncCore(t, AsyncOptions{Policy: AsyncDropNewest, ReportInterval: 10 * time.Millisecond})
+	_ = core.Write(zapcore.Entry{Level: zapcore.InfoLevel, Message: "dropped"}, nil)
+	_ = core.Write(zapcore.Entry{Level: zapcore.InfoLevel, Message: "dropped"}, nil)
+	close(writer.open)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entries := writer.buf.entries(t)
+		if len(entries) == 3 {
+			if report := entries[2]; report["msg"] != "async log queue full entries dropped" || report["dropped"] != float64(2) {
+				t.Fatalf("report = %v", report)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("entries = %q, want first, second and the report", writer.buf.lines())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
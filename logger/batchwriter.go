@@ -0,0 +1,250 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchMaxEntries   = 256
+	defaultBatchMaxBytes     = 1024 * 1024
+	defaultBatchInterval     = time.Second
+	defaultBatchQueueEntries = 10000
+	defaultBatchMaxRetries   = 3
+	defaultBatchMinBackoff   = 100 * time.Millisecond
+	defaultBatchMaxBackoff   = 5 * time.Second
+)
+
+// batchOptions control when a batchWriter flushes and how it retries.
+type batchOptions struct {
+	maxEntries   int           // flush when this many entries are pending
+	maxBytes     int           // flush when this many bytes are pending
+	interval     time.Duration // flush at least this often
+	queueEntries int           // drop new entries when this many are pending
+	maxRetries   int           // retries after the first failed send of a batch
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+}
+
+func (o *batchOptions) setDefaults() {
+	if o.maxEntries <= 0 {
+		o.maxEntries = defaultBatchMaxEntries
+	}
+	if o.maxBytes <= 0 {
+		o.maxBytes = defaultBatchMaxBytes
+	}
+	if o.interval <= 0 {
+		o.interval = defaultBatchInterval
+	}
+	if o.queueEntries <= 0 {
+		o.queueEntries = defaultBatchQueueEntries
+	}
+	if o.queueEntries < o.maxEntries {
+		o.queueEntries = o.maxEntries
+	}
+	if o.maxRetries < 0 {
+		o.maxRetries = 0
+	}
+	if o.minBackoff <= 0 {
+		o.minBackoff = defaultBatchMinBackoff
+	}
+	if o.maxBackoff < o.minBackoff {
+		o.maxBackoff = defaultBatchMaxBackoff
+	}
+}
+
+// batchSender delivers a batch of encoded entries to a remote endpoint.
+type batchSender interface {
+	send(batch [][]byte) error
+}
+
+// batchWriter accumulates encoded entries in a bounded in-memory queue and hands them to a batchSender from a
+// background goroutine. Failed sends are retried with jittered exponential backoff and then reported to onError.
+type batchWriter struct {
+	opts    batchOptions
+	sender  batchSender
+	onError func(err error)
+
+	mutex        sync.Mutex // protects pending, pendingBytes, dropped and closed
+	pending      [][]byte
+	pendingBytes int
+	dropped      uint64
+	closed       bool
+
+	sendMutex sync.Mutex // serializes sends from the background goroutine and Sync
+	flushCh   chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newBatchWriter(sender batchSender, opts batchOptions, onError func(err error)) *batchWriter {
+	opts.setDefaults()
+	w := &batchWriter{
+		opts:    opts,
+		sender:  sender,
+		onError: onError,
+		flushCh: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// add queues an encoded entry. If the queue is full the entry is dropped and counted.
+func (w *batchWriter) add(entry []byte) {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return
+	}
+	if len(w.pending) >= w.opts.queueEntries {
+		w.dropped++
+		w.mutex.Unlock()
+		return
+	}
+	w.pending = append(w.pending, entry)
+	w.pendingBytes += len(entry)
+	full := len(w.pending) >= w.opts.maxEntries || w.pendingBytes >= w.opts.maxBytes
+	w.mutex.Unlock()
+
+	if full {
+		select {
+		case w.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Sync sends everything queued so far and returns the last send error.
+func (w *batchWriter) Sync() error {
+	return w.flush()
+}
+
+// Close stops the background goroutine, sends everything queued so far and closes the sender if it is an
+// io.Closer. Entries added after Close are discarded.
+func (w *batchWriter) Close() (err error) {
+	w.closeOnce.Do(func() {
+		w.mutex.Lock()
+		w.closed = true
+		w.mutex.Unlock()
+		close(w.done)
+		err = w.flush()
+		if closer, isCloser := w.sender.(io.Closer); isCloser {
+			if closeErr := closer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	})
+	return
+}
+
+func (w *batchWriter) run() {
+	ticker := time.NewTicker(w.opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.flushCh:
+		case <-w.done:
+			return
+		}
+		_ = w.flush()
+	}
+}
+
+// takeBatch removes up to maxEntries / maxBytes worth of entries from the front of the queue.
+func (w *batchWriter) takeBatch() (batch [][]byte, dropped uint64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	var n, size int
+	for n < len(w.pending) && n < w.opts.maxEntries {
+		if n > 0 && size+len(w.pending[n]) > w.opts.maxBytes {
+			break
+		}
+		size += len(w.pending[n])
+		n++
+	}
+	batch = w.pending[:n:n]
+	w.pending = w.pending[n:]
+	w.pendingBytes -= size
+	if len(w.pending) == 0 {
+		w.pending = nil
+	}
+
+	dropped = w.dropped
+	w.dropped = 0
+	return
+}
+
+func (w *batchWriter) flush() (err error) {
+	w.sendMutex.Lock()
+	defer w.sendMutex.Unlock()
+
+	for {
+		batch, dropped := w.takeBatch()
+		if dropped > 0 && w.onError != nil {
+			w.onError(&droppedEntriesError{count: dropped})
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if sendErr := w.sendWithRetry(batch); sendErr != nil {
+			err = sendErr
+			if w.onError != nil {
+				w.onError(sendErr)
+			}
+		}
+	}
+}
+
+func (w *batchWriter) sendWithRetry(batch [][]byte) (err error) {
+	backoff := w.opts.minBackoff
+	for attempt := 0; ; attempt++ {
+		if err = w.sender.send(batch); err == nil || attempt >= w.opts.maxRetries {
+			return
+		}
+		if errors.As(err, new(permanentSendError)) {
+			return
+		}
+		time.Sleep(jitter(backoff))
+		backoff *= 2
+		if backoff > w.opts.maxBackoff {
+			backoff = w.opts.maxBackoff
+		}
+	}
+}
+
+// jitter returns a random duration in [d/2, d).
+func jitter(d time.Duration) time.Duration {
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+	return time.Duration(half + rand.Int63n(half))
+}
+
+type droppedEntriesError struct {
+	count uint64
+}
+
+func (e *droppedEntriesError) Error() string {
+	return "log queue full dropped " + strconv.FormatUint(e.count, 10) + " entries"
+}
+
+// permanentSendError is returned by a batchSender when retrying the same batch cannot succeed.
+type permanentSendError struct {
+	err error
+}
+
+func (e permanentSendError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentSendError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// defaultBenignErrors are treated like a cancelled context by the IgnoreCancel methods until SetBenignErrors is
+// called.
+var defaultBenignErrors = []error{
+	net.ErrClosed,
+	io.EOF,
+	http.ErrServerClosed,
+}
+
+// SetBenignErrors replaces the errors that, like context.Canceled and context.DeadlineExceeded, make the
+// IgnoreCancel methods drop an entry when a field holds an error matching one of them with errors.Is. The defaults
+// are net.ErrClosed, io.EOF and http.ErrServerClosed.
+func (s *Logger) SetBenignErrors(errs ...error) {
+	benignErrors := make([]error, len(errs))
+	copy(benignErrors, errs)
+	s.benignErrors.Store(&benignErrors)
+}
+
+// isCancellation reports whether err is or wraps a context cancellation or one of the benign errors. errors.Is
+// walks wrapped chains and errors.Join trees.
+func (s *Logger) isCancellation(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	benignErrors := defaultBenignErrors
+	if configured := s.benignErrors.Load(); configured != nil {
+		benignErrors = *configured
+	}
+	for _, benignErr := range benignErrors {
+		if errors.Is(err, benignErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldsContainContextCancelled reports whether any error field holds a cancellation, see isCancellation.
+func (s *Logger) fieldsContainContextCancelled(fields ...Field) bool {
+	for i := range fields {
+		if fieldErr, fieldIsError := fieldError(fields[i]); fieldIsError && fieldErr != nil && s.isCancellation(fieldErr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+var errBenignTest = errors.New("benign test error")
+
+func TestFieldsContainContextCancelled(t *testing.T) {
+	tests := []struct {
+		name   string
+		benign []error
+		field  Field
+		want   bool
+	}{
+		{name: "canceled", field: Error(context.Canceled), want: true},
+		{name: "wrapped canceled", field: Error(fmt.Errorf("read: %w", context.Canceled)), want: true},
+		{name: "wrapped deadline", field: Error(fmt.Errorf("read: %w", context.DeadlineExceeded)), want: true},
+		{name: "joined", field: Error(errors.Join(errors.New("other"), context.Canceled)), want: true},
+		{name: "named error", field: NamedError("cause", fmt.Errorf("read: %w", context.Canceled)), want: true},
+		{name: "zap error", field: Any("cause", context.Canceled), want: true},
+		{name: "default benign", field: Error(fmt.Errorf("read: %w", io.EOF)), want: true},
+		{name: "custom benign", benign: []error{errBenignTest}, field: Error(fmt.Errorf("read: %w", errBenignTest)), want: true},
+		{name: "replaced defaults", benign: []error{errBenignTest}, field: Error(io.EOF), want: false},
+		{name: "other error", field: Error(errors.New("disk full")), want: false},
+		{name: "string", field: String("error", context.Canceled.Error()), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewLogger()
+			if tt.benign != nil {
+				s.SetBenignErrors(tt.benign...)
+			}
+			if got := s.fieldsContainContextCancelled(String("k", "v"), tt.field); got != tt.want {
+				t.Fatalf("fieldsContainContextCancelled = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreCancelLogsOtherErrors(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	s.SetBenignErrors(errBenignTest)
+	ctx := context.Background()
+	s.ErrorIgnoreCancel(ctx, "benign", Error(fmt.Errorf("read: %w", errBenignTest)))
+	s.ErrorIgnoreCancel(ctx, "cancelled", Error(context.Canceled))
+	s.ErrorIgnoreCancel(ctx, "failed", Error(errors.New("disk full")))
+	s.WarnIgnoreCancel(ctx, "warned", Error(errors.New("slow disk")))
+
+	entries := buf.entries(t)
+	if len(entries) != 2 {
+		t.Fatalf("entries = %v, want failed and warned", entries)
+	}
+	if entries[0]["msg"] != "failed" || entries[0]["level"] != "error" || entries[1]["msg"] != "warned" || entries[1]["level"] != "warn" {
+		t.Fatalf("entries = %v", entries)
+	}
+}
@@ -0,0 +1,49 @@
+package logger
+
+import (
+	"context"
+)
+
+// CancelAction is what a ContextLogger does with an entry at Error or below once its context is cancelled or a field
+// holds a cancellation error (see SetBenignErrors).
+type CancelAction int
+
+//goland:noinspection GoUnusedConst
+const (
+	// CancelLog writes the entry as usual.
+	CancelLog CancelAction = iota
+	// CancelDrop drops the entry.
+	CancelDrop
+	// CancelDowngrade writes the entry at Debug with a "cancelled" field.
+	CancelDowngrade
+)
+
+// WithCancelAction makes every ContextLogger of ctx apply action to entries at Error or below once ctx is cancelled
+// or a field holds a cancellation error. Panic, DPanic and Fatal entries are always written.
+// example: ctx = logger.WithCancelAction(ctx, logger.CancelDowngrade)
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithCancelAction(ctx context.Context, action CancelAction) context.Context {
+	return context.WithValue(ctx, loggerCancelActionContextKey, action)
+}
+
+// IgnoringCancel returns a copy of the ContextLogger whose level methods drop entries at Error or below once its
+// context is cancelled or a field holds a cancellation error. It replaces the IgnoreCancel variants.
+// example: logger.OfMust(ctx).IgnoringCancel().Error("read failed", logger.Error(err))
+func (s *ContextLogger) IgnoringCancel() *ContextLogger {
+	return s.WithCancelAction(CancelDrop)
+}
+
+// WithCancelAction returns a copy of the ContextLogger that applies action to entries at Error or below once its
+// context is cancelled or a field holds a cancellation error.
+func (s *ContextLogger) WithCancelAction(action CancelAction) *ContextLogger {
+	clone := *s
+	clone.cancelAction = action
+	return &clone
+}
+
+// cancelled reports whether the context of the ContextLogger is done or fields or the context fields hold a
+// cancellation error.
+func (s *ContextLogger) cancelled(fields []Field) bool {
+	return (s.ctx != nil && s.ctx.Err() != nil) || s.contextCancelled(fields)
+}
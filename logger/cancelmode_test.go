@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// cancelMessages returns the level and message of the entries written to buf, with "+cancelled" for entries marked
+// cancelled.
+func cancelMessages(t *testing.T, buf *syncBuffer) (messages []string) {
+	t.Helper()
+	for _, entry := range buf.entries(t) {
+		message := entry["level"].(string) + ":" + entry["msg"].(string)
+		if entry["cancelled"] == true {
+			message += "+cancelled"
+		}
+		messages = append(messages, message)
+	}
+	return
+}
+
+func TestCancelActions(t *testing.T) {
+	tests := []struct {
+		name   string
+		action CancelAction
+		want   []string
+	}{
+		{name: "log", action: CancelLog, want: []string{"error:field", "info:info", "error:error", "dpanic:dpanic"}},
+		{name: "drop", action: CancelDrop, want: []string{"dpanic:dpanic"}},
+		{name: "downgrade", action: CancelDowngrade, want: []string{
+			"debug:field+cancelled", "debug:info+cancelled", "debug:error+cancelled", "dpanic:dpanic",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, buf := newTestLogger(t, DebugLevel)
+			ctx, cancel := context.WithCancel(WithCancelAction(WithLogger(context.Background(), s), tt.action))
+			log := OfMust(ctx)
+			// a cancellation error in a field applies the action before the context is done
+			log.Error("field", Error(context.Canceled))
+			cancel()
+			log.Info("info")
+			log.Error("error")
+			log.DPanic("dpanic")
+
+			// levels above Error are left alone
+			if got := cancelMessages(t, buf); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("entries = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoringCancel(t *testing.T) {
+	s, buf := newTestLogger(t, DebugLevel)
+	log := OfMust(WithLogger(context.Background(), s)).IgnoringCancel()
+	log.Error("dropped", Error(context.Canceled))
+	log.Error("failed", Error(errors.New("disk full")))
+
+	if want := []string{"error:failed"}; !reflect.DeepEqual(cancelMessages(t, buf), want) {
+		t.Fatalf("entries = %q, want %q", cancelMessages(t, buf), want)
+	}
+}
+
+func TestCancelDowngradeDoesNotWriteCallerFields(t *testing.T) {
+	s, _ := newTestLogger(t, DebugLevel)
+	ctx, cancel := context.WithCancel(WithCancelAction(WithLogger(context.Background(), s), CancelDowngrade))
+	cancel()
+
+	backing := make([]Field, 1, 2)
+	backing[0] = String("k", "v")
+	OfMust(ctx).Error("cancelled", backing...)
+	if extended := backing[:2]; extended[1].Key != "" {
+		t.Fatalf("caller backing array written: %v", extended[1])
+	}
+}
@@ -4,7 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 type contextKey int
@@ -12,16 +13,27 @@ type contextKey int
 const (
 	loggerContextKey contextKey = iota + 1
 	loggerFieldsContextKey
+	loggerForcedLevelContextKey
+	loggerRequestBufferContextKey
+	loggerCancelActionContextKey
 )
 
 var allContextParams = []contextKey{
 	loggerContextKey,
 	loggerFieldsContextKey,
+	loggerForcedLevelContextKey,
+	loggerRequestBufferContextKey,
+	loggerCancelActionContextKey,
 }
 
 type ContextLogger struct {
-	logger *Logger
-	fields *ContextFields
+	ctx          context.Context
+	logger       *Logger
+	fields       *ContextFields
+	forced       bool
+	forcedLevel  zapcore.Level
+	buffer       *requestBuffer
+	cancelAction CancelAction
 }
 
 type ContextFields struct {
@@ -51,11 +63,12 @@ func WithFields(ctx context.Context, newFields ...Field) context.Context {
 		copy(cFields.fields, existingCFields.fields)
 	}
 
-	// loop on new fields and either replace a value with the same key or add it if not found
+	// loop on new fields and either replace a value with the same key or add it if not found, fields without a key
+	// like Group("", ...) are always added
 	for _, newField := range newFields {
 		var foundKey bool
 		for j := range cFields.fields {
-			if cFields.fields[j].Key == newField.Key {
+			if newField.Key != "" && cFields.fields[j].Key == newField.Key {
 				cFields.fields[j] = newField
 				foundKey = true
 				break
@@ -74,6 +87,13 @@ func WithoutFields(ctx context.Context) context.Context {
 	return context.WithValue(ctx, loggerFieldsContextKey, make([]Field, 0))
 }
 
+// WithForcedLevel makes every ContextLogger of ctx write entries at level and above to every enabled LogInstance
+// regardless of the instance levels, e.g. for a request with a debug header. Entries an instance would not have
+// accepted otherwise are marked with a "forced" field.
+func WithForcedLevel(ctx context.Context, level Level) context.Context {
+	return context.WithValue(ctx, loggerForcedLevelContextKey, level)
+}
+
 func OfMust(ctx context.Context) (clogger *ContextLogger) {
 	var err error
 	clogger, err = Of(ctx)
@@ -90,9 +110,16 @@ func Of(ctx context.Context) (clogger *ContextLogger, err error) {
 		return
 	}
 	clogger = &ContextLogger{
+		ctx:    ctx,
 		logger: logger,
 	}
 	clogger.fields, _ = fieldsOf(ctx)
+	if forcedLevel, forced := ctx.Value(loggerForcedLevelContextKey).(Level); forced {
+		clogger.forced = true
+		clogger.forcedLevel = zapcore.Level(forcedLevel)
+	}
+	clogger.buffer = requestBufferOf(ctx)
+	clogger.cancelAction, _ = ctx.Value(loggerCancelActionContextKey).(CancelAction)
 	return
 }
 
@@ -105,168 +132,157 @@ func (s *ContextLogger) Logger() *Logger {
 	return s.logger
 }
 
-func (s *ContextLogger) Trace(msg string, fields ...Field) {
+// log writes an entry with fields and the context fields. Like Logger.log it must be called directly by the exported
+// level methods.
+func (s *ContextLogger) log(level zapcore.Level, msg string, fields []Field) {
+	if s.cancelAction != CancelLog && level <= zapcore.ErrorLevel && s.cancelled(fields) {
+		if s.cancelAction == CancelDrop {
+			return
+		}
+		if level > zapcore.DebugLevel {
+			level = zapcore.DebugLevel
+			// copied so the backing array of a variadic fields... argument is not written
+			marked := make([]Field, len(fields), len(fields)+1)
+			copy(marked, fields)
+			fields = append(marked, Bool("cancelled", true))
+		}
+	}
+	var contextFields []Field
 	if s.fields != nil {
-		fields = append(fields, s.fields.fields...)
+		contextFields = s.fields.fields
+	}
+	logger := s.dispatchLogger(level, fields)
+	if logger == nil {
+		if recorder := s.logger.config().flightRecorder; recorder != nil {
+			recorder.record(s.logger, level, msg, fields, contextFields)
+		}
+		return
 	}
-	cfg := s.logger.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Debug(msg, fieldsToZapFields(fields...)...)
+	if ce := logger.Check(level, msg); ce != nil {
+		fields = s.logger.resolveFields(msg, fields, contextFields)
+		if s.buffer != nil {
+			s.buffer.write(ce, fieldsToZapFields(fields...))
+			return
 		}
+		ce.Write(fieldsToZapFields(fields...)...)
 	}
 }
 
-func (s *ContextLogger) Debug(msg string, fields ...Field) {
-	if s.fields != nil {
-		fields = append(fields, s.fields.fields...)
+// dispatchLogger returns the zap logger to check an entry at level with the call-site fields, or nil if no enabled
+// LogInstance would accept it. WithForcedLevel or a level rule matching the entry fields selects the logger that
+// ignores instance levels.
+func (s *ContextLogger) dispatchLogger(level zapcore.Level, fields []Field) *zap.Logger {
+	if forcedLevel, forced := s.forcedLevelOf(level, fields); forced && level >= forcedLevel {
+		return s.logger.loggersOf(s.logger.config()).forced
 	}
-	cfg := s.logger.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Debug(msg, fieldsToZapFields(fields...)...)
-		}
+	if level < zapcore.DPanicLevel && !s.logger.enabled(level) {
+		return nil
 	}
+	return s.logger.loggersOf(s.logger.config()).logger
 }
 
-func (s *ContextLogger) Info(msg string, fields ...Field) {
+// forcedLevelOf returns the lower of the level forced by the context and the level of the level rules matching the
+// call-site fields, the Logger.With fields and the context fields of an entry at entryLevel.
+func (s *ContextLogger) forcedLevelOf(entryLevel zapcore.Level, fields []Field) (level zapcore.Level, forced bool) {
+	var contextFields []Field
 	if s.fields != nil {
-		fields = append(fields, s.fields.fields...)
+		contextFields = s.fields.fields
 	}
-	cfg := s.logger.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Info(msg, fieldsToZapFields(fields...)...)
-		}
+	level, forced = s.logger.ruleLevel(entryLevel, fields, contextFields)
+	if s.forced && (!forced || s.forcedLevel < level) {
+		level, forced = s.forcedLevel, true
 	}
+	return
 }
 
-func (s *ContextLogger) InfoIgnoreCancel(ctx context.Context, msg string, fields ...Field) {
-	if ctx.Err() != nil {
-		return
+// check is the ContextLogger counterpart of Logger.check.
+func (s *ContextLogger) check(level zapcore.Level, msg string) *zapcore.CheckedEntry {
+	logger := s.dispatchLogger(level, nil)
+	if logger == nil {
+		return nil
 	}
-	if s.fields != nil {
-		fields = append(fields, s.fields.fields...)
-	}
-	if fieldsContainContextCancelled(fields...) {
+	return logger.Check(level, msg)
+}
+
+// contextCancelled reports whether the context fields or fields contain a context cancelled error.
+func (s *ContextLogger) contextCancelled(fields []Field) bool {
+	return s.logger.fieldsContainContextCancelled(fields...) ||
+		(s.fields != nil && s.logger.fieldsContainContextCancelled(s.fields.fields...))
+}
+
+func (s *ContextLogger) Trace(msg string, fields ...Field) {
+	s.log(zapcore.Level(TraceLevel), msg, fields)
+}
+
+func (s *ContextLogger) Debug(msg string, fields ...Field) {
+	s.log(zapcore.DebugLevel, msg, fields)
+}
+
+func (s *ContextLogger) Info(msg string, fields ...Field) {
+	s.log(zapcore.InfoLevel, msg, fields)
+}
+
+func (s *ContextLogger) InfoIgnoreCancel(ctx context.Context, msg string, fields ...Field) {
+	if ctx.Err() != nil || s.contextCancelled(fields) {
 		return
 	}
-	cfg := s.logger.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Info(msg, fieldsToZapFields(fields...)...)
-		}
-	}
+	s.log(zapcore.InfoLevel, msg, fields)
 }
 
 func (s *ContextLogger) Warn(msg string, fields ...Field) {
-	if s.fields != nil {
-		fields = append(fields, s.fields.fields...)
-	}
-	cfg := s.logger.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Warn(msg, fieldsToZapFields(fields...)...)
-		}
-	}
+	s.log(zapcore.WarnLevel, msg, fields)
 }
 
 func (s *ContextLogger) WarnIgnoreCancel(ctx context.Context, msg string, fields ...Field) {
-	if ctx.Err() != nil {
+	if ctx.Err() != nil || s.contextCancelled(fields) {
 		return
 	}
-	if s.fields != nil {
-		fields = append(fields, s.fields.fields...)
-	}
-	if fieldsContainContextCancelled(fields...) {
-		return
-	}
-	cfg := s.logger.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Warn(msg, fieldsToZapFields(fields...)...)
-		}
-	}
+	s.log(zapcore.WarnLevel, msg, fields)
 }
 
 func (s *ContextLogger) Error(msg string, fields ...Field) {
-	if s.fields != nil {
-		fields = append(fields, s.fields.fields...)
-	}
-	cfg := s.logger.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Error(msg, fieldsToZapFields(fields...)...)
-		}
-	}
+	s.log(zapcore.ErrorLevel, msg, fields)
 }
 
 func (s *ContextLogger) ErrorIgnoreCancel(ctx context.Context, msg string, fields ...Field) {
-	if ctx.Err() != nil {
-		return
-	}
-	if s.fields != nil {
-		fields = append(fields, s.fields.fields...)
-	}
-	if fieldsContainContextCancelled(fields...) {
+	if ctx.Err() != nil || s.contextCancelled(fields) {
 		return
 	}
-	cfg := s.logger.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Error(msg, fieldsToZapFields(fields...)...)
-		}
-	}
+	s.log(zapcore.ErrorLevel, msg, fields)
 }
 
 func (s *ContextLogger) Panic(msg string, fields ...Field) {
-	if s.fields != nil {
-		fields = append(fields, s.fields.fields...)
-	}
-	cfg := s.logger.config()
-	var foundLogger bool
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			foundLogger = true
-			logInstance.logger.Panic(msg, fieldsToZapFields(fields...)...)
-		}
-	}
-	if !foundLogger {
-		panic(msg)
-	}
+	s.log(zapcore.PanicLevel, msg, fields)
 }
 
 func (s *ContextLogger) DPanic(msg string, fields ...Field) {
-	if s.fields != nil {
-		fields = append(fields, s.fields.fields...)
-	}
-	cfg := s.logger.config()
-	var foundLogger bool
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			foundLogger = true
-			logInstance.logger.DPanic(msg, fieldsToZapFields(fields...)...)
-		}
-	}
-	if !foundLogger {
-		panic(msg)
+	if cfg := s.logger.config(); !cfg.anyEnabled {
+		cfg.terminate(msg, false)
 	}
+	s.log(zapcore.DPanicLevel, msg, fields)
 }
 
 func (s *ContextLogger) Fatal(msg string, fields ...Field) {
-	if s.fields != nil {
-		fields = append(fields, s.fields.fields...)
+	if !s.logger.config().anyEnabled {
+		fmt.Println(msg)
 	}
-	cfg := s.logger.config()
-	var foundLogger bool
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			foundLogger = true
-			logInstance.logger.Fatal(msg, fieldsToZapFields(fields...)...)
-		}
+	s.log(zapcore.FatalLevel, msg, fields)
+}
+
+// Check returns a CheckedEntry if an entry at level would be written by any enabled LogInstance, otherwise nil. The
+// context fields are added when the entry is written.
+func (s *ContextLogger) Check(level Level, msg string) *CheckedEntry {
+	ce := s.check(zapcore.Level(level), msg)
+	if ce == nil {
+		return nil
+	}
+	checked := &CheckedEntry{
+		ce:     ce,
+		logger: s.logger,
+		buffer: s.buffer,
 	}
-	if !foundLogger {
-		fmt.Println(msg)
-		os.Exit(1)
+	if s.fields != nil {
+		checked.contextFields = s.fields.fields
 	}
+	return checked
 }
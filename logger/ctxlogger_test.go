@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// forcedMessages returns the messages written to buf, prefixed with "forced:" for entries marked forced.
+func forcedMessages(t *testing.T, buf *syncBuffer) (messages []string) {
+	t.Helper()
+	for _, entry := range buf.entries(t) {
+		message := entry["msg"].(string)
+		if entry["forced"] == true {
+			message = "forced:" + message
+		}
+		messages = append(messages, message)
+	}
+	return
+}
+
+func TestWithForcedLevel(t *testing.T) {
+	s, buf := newTestLogger(t, WarnLevel)
+	ctx := WithForcedLevel(WithLogger(context.Background(), s), DebugLevel)
+	log := OfMust(ctx)
+	log.Trace("trace")
+	log.Debug("debug")
+	log.Info("info")
+	log.Warn("warn")
+	OfMust(WithLogger(context.Background(), s)).Debug("not forced")
+
+	if want := []string{"forced:debug", "forced:info", "warn"}; !reflect.DeepEqual(forcedMessages(t, buf), want) {
+		t.Fatalf("messages = %q, want %q", forcedMessages(t, buf), want)
+	}
+}
+
+func TestWithAllValuesCarriesForcedLevel(t *testing.T) {
+	s, buf := newTestLogger(t, WarnLevel)
+	from := WithForcedLevel(WithLogger(context.Background(), s), DebugLevel)
+	OfMust(WithAllValues(context.Background(), from)).Debug("carried")
+
+	if want := []string{"forced:carried"}; !reflect.DeepEqual(forcedMessages(t, buf), want) {
+		t.Fatalf("messages = %q, want %q", forcedMessages(t, buf), want)
+	}
+}
+
+func TestWithForcedLevelAndLevelRule(t *testing.T) {
+	tests := []struct {
+		name        string
+		forcedLevel Level
+		ruleLevel   Level
+	}{
+		{name: "rule lower", forcedLevel: InfoLevel, ruleLevel: DebugLevel},
+		{name: "forced lower", forcedLevel: DebugLevel, ruleLevel: InfoLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, buf := newTestLogger(t, WarnLevel)
+			s.AddLevelRule("u1", LevelRule{Key: "user_id", Value: "u1", Level: tt.ruleLevel})
+			log := OfMust(WithForcedLevel(WithLogger(context.Background(), s), tt.forcedLevel))
+			log.Trace("trace", String("user_id", "u1"))
+			log.Debug("debug", String("user_id", "u1"))
+			log.Debug("other user", String("user_id", "u2"))
+
+			want := []string{"forced:debug"}
+			if tt.forcedLevel == DebugLevel {
+				want = append(want, "forced:other user")
+			}
+			if !reflect.DeepEqual(forcedMessages(t, buf), want) {
+				t.Fatalf("messages = %q, want %q", forcedMessages(t, buf), want)
+			}
+		})
+	}
+}
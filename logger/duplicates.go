@@ -0,0 +1,165 @@
+package logger
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// WithStrictDuplicateKeys reports every entry with a duplicate field key through ErrorInLoggerWriter, to find
+// collisions during development. Duplicates are resolved the same way with or without it.
+// example: logger.Instance().StartTask(logger.WithStrictDuplicateKeys())
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithStrictDuplicateKeys() LoggingOption {
+	return func(o *Options) {
+		o.strictDuplicateKeys = true
+	}
+}
+
+// resolveFields returns the call-site fields followed by the context fields with one field per key. A call-site field
+// overrides a Logger.With field which overrides a context field, and among call-site fields the last one wins. The
+// Logger.With fields themselves are resolved by the dedupCore of each LogInstance. Error fields are resolved by their
+// key with the key_type, key_chain and key_stack they add; fields without a key, like Group("", ...), are kept as is.
+func (s *Logger) resolveFields(msg string, fields []Field, contextFields []Field) []Field {
+	strict := s.config().options.strictDuplicateKeys
+	var duplicates []string
+
+	resolved := fields
+	copied := false
+	for i := range fields {
+		key := fields[i].Key
+		if key == "" {
+			if copied {
+				resolved = append(resolved, fields[i])
+			}
+			continue
+		}
+		if keyIndex(fields[i+1:], key) >= 0 {
+			duplicates = appendKey(duplicates, key)
+			if !copied {
+				// copy on the first duplicate so the caller's slice is not modified
+				resolved = make([]Field, i, len(fields)+len(contextFields))
+				copy(resolved, fields[:i])
+				copied = true
+			}
+			continue
+		}
+		if copied {
+			resolved = append(resolved, fields[i])
+		}
+		if strict && containsKey(s.withKeys, key) {
+			duplicates = appendKey(duplicates, key)
+		}
+	}
+
+	for _, contextField := range contextFields {
+		key := contextField.Key
+		if key != "" && (keyIndex(fields, key) >= 0 || containsKey(s.withKeys, key)) {
+			duplicates = appendKey(duplicates, key)
+			continue
+		}
+		resolved = append(resolved, contextField)
+	}
+
+	if strict && len(duplicates) > 0 {
+		s.ErrorInLoggerWriter("duplicate log field keys %v in entry %q", duplicates, msg)
+	}
+	return resolved
+}
+
+func keyIndex(fields []Field, key string) int {
+	for i := range fields {
+		if fields[i].Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// appendKey appends key to keys unless it is already there.
+func appendKey(keys []string, key string) []string {
+	if containsKey(keys, key) {
+		return keys
+	}
+	return append(keys, key)
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// dedupCore resolves duplicate keys between the fields a LogInstance was given with With and the fields of an entry.
+// With fields are still encoded once; only an entry or With call that overrides one of them is encoded from base.
+type dedupCore struct {
+	zapcore.Core
+	// base is the core without With fields
+	base       zapcore.Core
+	withFields []zapcore.Field
+}
+
+func newDedupCore(core zapcore.Core) zapcore.Core {
+	return &dedupCore{
+		Core: core,
+		base: core,
+	}
+}
+
+func (c *dedupCore) With(fields []zapcore.Field) zapcore.Core {
+	withFields, overridden := overrideFields(c.withFields, fields)
+	var core zapcore.Core
+	if overridden {
+		core = c.base.With(withFields)
+	} else {
+		core = c.Core.With(fields)
+	}
+	return &dedupCore{
+		Core:       core,
+		base:       c.base,
+		withFields: withFields,
+	}
+}
+
+func (c *dedupCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *dedupCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	for i := range fields {
+		if fields[i].Key != "" && zapKeyIndex(c.withFields, fields[i].Key) >= 0 {
+			merged, _ := overrideFields(c.withFields, fields)
+			return c.base.Write(ent, merged)
+		}
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// overrideFields returns fields followed by overrides, without the fields whose key is in overrides, and whether
+// any were left out.
+func overrideFields(fields []zapcore.Field, overrides []zapcore.Field) (merged []zapcore.Field, overridden bool) {
+	merged = make([]zapcore.Field, 0, len(fields)+len(overrides))
+	for _, field := range fields {
+		if field.Key != "" && zapKeyIndex(overrides, field.Key) >= 0 {
+			overridden = true
+			continue
+		}
+		merged = append(merged, field)
+	}
+	merged = append(merged, overrides...)
+	return
+}
+
+func zapKeyIndex(fields []zapcore.Field, key string) int {
+	for i := range fields {
+		if fields[i].Key == key {
+			return i
+		}
+	}
+	return -1
+}
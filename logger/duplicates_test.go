@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDuplicateKeyPrecedence(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	with := s.With(Error(fmt.Errorf("with")), String("user_id", "with"), String("svc", "with"))
+	ctx := WithLogger(context.Background(), with)
+	ctx = WithFields(ctx, Error(fmt.Errorf("context")), String("user_id", "context"), String("req", "context"))
+	clogger := OfMust(ctx)
+
+	tests := []struct {
+		name string
+		log  func()
+		want map[string]string
+	}{
+		{
+			name: "call-site overrides With and context",
+			log:  func() { clogger.Info("entry", Error(fmt.Errorf("call")), String("user_id", "call")) },
+			want: map[string]string{"error": "call", "user_id": "call", "svc": "with", "req": "context"},
+		},
+		{
+			name: "With overrides context",
+			log:  func() { clogger.Info("entry") },
+			want: map[string]string{"error": "with", "user_id": "with", "svc": "with", "req": "context"},
+		},
+		{
+			name: "last call-site field wins",
+			log:  func() { s.Info("entry", String("a", "1"), String("a", "2")) },
+			want: map[string]string{"a": "2"},
+		},
+		{
+			name: "later With overrides earlier With",
+			log:  func() { with.With(String("svc", "child")).Info("entry") },
+			want: map[string]string{"svc": "child", "user_id": "with", "error": "with"},
+		},
+		{
+			name: "Check entry",
+			log: func() {
+				clogger.Check(InfoLevel, "entry").Write(String("req", "call"))
+			},
+			want: map[string]string{"req": "call", "user_id": "with"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.log()
+			line := buf.lines()[len(buf.lines())-1]
+			entry := lastEntry(t, buf)
+			for key, want := range tt.want {
+				if count := strings.Count(line, `"`+key+`":`); count != 1 {
+					t.Errorf("key %s appears %d times in %s", key, count, line)
+				}
+				if entry[key] != want {
+					t.Errorf("%s = %v, want %s", key, entry[key], want)
+				}
+			}
+			if count := strings.Count(line, `"error_type":`); strings.Contains(line, `"error":`) && count != 1 {
+				t.Errorf("error_type appears %d times in %s", count, line)
+			}
+		})
+	}
+}
+
+func TestStrictDuplicateKeysReports(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	s := NewLogger()
+	s.StartTask(WithStrictDuplicateKeys())
+	os.Stdout = stdout
+	s.SetLoggerEnabled(jsonStdoutKey, true)
+	s.SetLogLevel(jsonStdoutKey, ErrorLevel)
+	s.AddLogger("test", io.Discard, InfoLevel)
+
+	with := s.With(String("user_id", "with"))
+	ctx := WithFields(WithLogger(context.Background(), with), String("req", "context"))
+	OfMust(ctx).Info("collides", String("user_id", "call"), String("req", "call"))
+	with.Info("no collision", String("other", "call"))
+	with.With(String("user_id", "child"))
+	s.StopTask()
+	_ = writer.Close()
+
+	out, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports := strings.Count(string(out), "duplicate log field keys")
+	if reports != 2 {
+		t.Fatalf("got %d duplicate key reports, want 2:\n%s", reports, out)
+	}
+	if !strings.Contains(string(out), `duplicate log field keys [user_id req] in entry \"collides\"`) {
+		t.Fatalf("entry collision not reported:\n%s", out)
+	}
+	if !strings.Contains(string(out), `duplicate log field keys [user_id] in With`) {
+		t.Fatalf("With collision not reported:\n%s", out)
+	}
+}
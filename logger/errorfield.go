@@ -0,0 +1,162 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap/zapcore"
+	"reflect"
+	"sync"
+)
+
+// maxErrorChain bounds the number of wrapped errors walked for error_chain.
+const maxErrorChain = 32
+
+// errorExtractor adds fields for errors in a chain that match its type.
+type errorExtractor func(err error) (fields []Field, matched bool)
+
+var (
+	errorExtractorsMutex sync.RWMutex
+	errorExtractors      []errorExtractor
+)
+
+// RegisterErrorExtractor adds fields to every error field whose error chain contains an error of type T, found with
+// errors.As. Register extractors at init, before logging.
+// example:
+//
+//	logger.RegisterErrorExtractor(func(err *api.Error) []logger.Field {
+//		return []logger.Field{logger.Int("http_status", err.Status)}
+//	})
+//
+//goland:noinspection GoUnusedExportedFunction
+func RegisterErrorExtractor[T error](extract func(err T) []Field) {
+	errorExtractorsMutex.Lock()
+	defer errorExtractorsMutex.Unlock()
+	errorExtractors = append(errorExtractors, func(err error) ([]Field, bool) {
+		var target T
+		if !errors.As(err, &target) {
+			return nil, false
+		}
+		return extract(target), true
+	})
+}
+
+// errorField is the zapcore.ObjectMarshaler of the fields created by Error and NamedError. It adds key with the
+// message, key_type, key_chain with the messages of the wrapped errors, key_stack and the fields of the registered
+// extractors inline.
+type errorField struct {
+	key string
+	err error
+}
+
+// newErrorField returns an inline field that keeps key as its Field.Key, so WithFields, redaction and duplicate key
+// resolution treat it as the field at key. Encoders ignore the key of inline fields.
+func newErrorField(key string, err error) Field {
+	return Field{
+		Key:       key,
+		Type:      zapcore.InlineMarshalerType,
+		Interface: errorField{key: key, err: err},
+	}
+}
+
+func (f errorField) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString(f.key, f.err.Error())
+	enc.AddString(f.key+"_type", fmt.Sprintf("%T", f.err))
+
+	if chain := errorChain(f.err); len(chain) > 0 {
+		_ = enc.AddArray(f.key+"_chain", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+			for _, wrapped := range chain {
+				arr.AppendString(wrapped.Error())
+			}
+			return nil
+		}))
+	}
+
+	if stack := errorStack(f.err); stack != "" {
+		enc.AddString(f.key+"_stack", stack)
+	}
+
+	errorExtractorsMutex.RLock()
+	extractors := errorExtractors
+	errorExtractorsMutex.RUnlock()
+	for _, extract := range extractors {
+		if fields, matched := extract(f.err); matched {
+			for _, field := range fields {
+				zapcore.Field(field).AddTo(enc)
+			}
+		}
+	}
+	return nil
+}
+
+// errorChain returns the errors wrapped by err, depth first, following Unwrap() error and Unwrap() []error.
+func errorChain(err error) (chain []error) {
+	pending := unwrapErrors(err)
+	for len(pending) > 0 && len(chain) < maxErrorChain {
+		next := pending[0]
+		pending = append(unwrapErrors(next), pending[1:]...)
+		if next != nil {
+			chain = append(chain, next)
+		}
+	}
+	return
+}
+
+func unwrapErrors(err error) []error {
+	switch wrapper := err.(type) {
+	case interface{ Unwrap() error }:
+		if wrapped := wrapper.Unwrap(); wrapped != nil {
+			return []error{wrapped}
+		}
+	case interface{ Unwrap() []error }:
+		return wrapper.Unwrap()
+	}
+	return nil
+}
+
+// errorStack returns the stack trace of the first error in the chain that has a StackTrace method, such as errors
+// from github.com/pkg/errors, formatted with %+v. Otherwise it returns the %+v form of the first fmt.Formatter that
+// differs from its message.
+func errorStack(err error) string {
+	chain := append([]error{err}, errorChain(err)...)
+	for _, candidate := range chain {
+		if stack, found := stackTrace(candidate); found {
+			return fmt.Sprintf("%+v", stack)
+		}
+	}
+	for _, candidate := range chain {
+		if _, isFormatter := candidate.(fmt.Formatter); isFormatter {
+			if verbose := fmt.Sprintf("%+v", candidate); verbose != candidate.Error() {
+				return verbose
+			}
+		}
+	}
+	return ""
+}
+
+// fieldError returns the error held by an error field.
+func fieldError(field Field) (err error, ok bool) {
+	switch field.Type {
+	case zapcore.ErrorType:
+		err, ok = field.Interface.(error)
+	case zapcore.InlineMarshalerType:
+		var errField errorField
+		if errField, ok = field.Interface.(errorField); ok {
+			err = errField.err
+		}
+	}
+	return
+}
+
+// stackTrace returns the result of the StackTrace method of err. The method of github.com/pkg/errors returns a type
+// of that package, so it is looked up by name rather than with an interface to avoid depending on it.
+func stackTrace(err error) (stack interface{}, found bool) {
+	value := reflect.ValueOf(err)
+	if value.Kind() == reflect.Pointer && value.IsNil() {
+		return
+	}
+	method := value.MethodByName("StackTrace")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return
+	}
+	return method.Call(nil)[0].Interface(), true
+}
@@ -0,0 +1,130 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tracedError has a StackTrace method like the errors of github.com/pkg/errors.
+type tracedError struct {
+	msg string
+}
+
+func (e tracedError) Error() string {
+	return e.msg
+}
+
+func (e tracedError) StackTrace() []string {
+	return []string{"main.handler", "main.main"}
+}
+
+// verboseError formats with details for %+v like the errors of github.com/pkg/errors.
+type verboseError struct {
+	msg string
+}
+
+func (e verboseError) Error() string {
+	return e.msg
+}
+
+func (e verboseError) Format(s fmt.State, verb rune) {
+	_, _ = fmt.Fprint(s, e.msg)
+	if verb == 'v' && s.Flag('+') {
+		_, _ = fmt.Fprint(s, "\nverbose details")
+	}
+}
+
+type statusError struct {
+	status int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("status %d", e.status)
+}
+
+func init() {
+	RegisterErrorExtractor(func(err *statusError) []Field {
+		return []Field{Int("http_status", err.status)}
+	})
+}
+
+func TestErrorFieldKeepsKey(t *testing.T) {
+	field := Error(fmt.Errorf("boom"))
+	if field.Key != "error" {
+		t.Fatalf("Error field key = %q, want error", field.Key)
+	}
+	if field := NamedError("cause", fmt.Errorf("boom")); field.Key != "cause" {
+		t.Fatalf("NamedError field key = %q, want cause", field.Key)
+	}
+}
+
+func TestWithFieldsKeepsKeylessAndErrorFields(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	ctx := WithLogger(context.Background(), s)
+	ctx = WithFields(ctx, Error(fmt.Errorf("context error")))
+	ctx = WithFields(ctx, Group("", String("a", "1")), Group("", String("b", "2")))
+	OfMust(ctx).Info("entry")
+
+	entry := lastEntry(t, buf)
+	if entry["error"] != "context error" || entry["a"] != "1" || entry["b"] != "2" {
+		t.Fatalf("context fields lost: %v", entry)
+	}
+
+	ctx = WithFields(ctx, Error(fmt.Errorf("replaced")))
+	OfMust(ctx).Info("entry")
+	if line := buf.lines()[len(buf.lines())-1]; strings.Count(line, `"error":`) != 1 || !strings.Contains(line, `"error":"replaced"`) {
+		t.Fatalf("error field not replaced by key: %s", line)
+	}
+}
+
+func TestErrorFieldStack(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	s.Info("entry", Error(fmt.Errorf("wrapped: %w", tracedError{msg: "cause"})))
+	s.Info("entry", Error(fmt.Errorf("wrapped: %w", verboseError{msg: "cause"})))
+	s.Info("entry", Error(errors.New("plain")))
+
+	entries := buf.entries(t)
+	if stack := entries[0]["error_stack"]; stack != "[main.handler main.main]" {
+		t.Fatalf("error_stack = %q, want the StackTrace result", stack)
+	}
+	if stack := entries[1]["error_stack"]; stack != "cause\nverbose details" {
+		t.Fatalf("error_stack = %q, want the %%+v form", stack)
+	}
+	if stack, found := entries[2]["error_stack"]; found {
+		t.Fatalf("error_stack = %q for an error without a stack", stack)
+	}
+	entry := entries[0]
+	if entry["error_type"] != "*fmt.wrapError" {
+		t.Fatalf("error_type = %v", entry["error_type"])
+	}
+}
+
+func TestErrorFieldChain(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	joined := errors.Join(errors.New("first"), fmt.Errorf("second: %w", &statusError{status: 503}))
+	s.Info("entry", NamedError("cause", fmt.Errorf("request: %w", joined)))
+	s.Info("entry", Error(errors.New("plain")))
+
+	entries := buf.entries(t)
+	var chain []string
+	for _, wrapped := range entries[0]["cause_chain"].([]interface{}) {
+		chain = append(chain, wrapped.(string))
+	}
+	want := []string{"first\nsecond: status 503", "first", "second: status 503", "status 503"}
+	if !reflect.DeepEqual(chain, want) {
+		t.Fatalf("cause_chain = %q, want %q", chain, want)
+	}
+	if entries[0]["http_status"] != float64(503) {
+		t.Fatalf("extractor field missing: %v", entries[0])
+	}
+	if _, found := entries[1]["error_chain"]; found {
+		t.Fatalf("error_chain for an error that wraps nothing: %v", entries[1])
+	}
+	if _, found := entries[1]["http_status"]; found {
+		t.Fatalf("extractor matched an unrelated error: %v", entries[1])
+	}
+}
@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"reflect"
+	"testing"
+)
+
+// exitRecorder records the exit hooks and exit func calls of a Logger, and whether the entry had been written when
+// they ran.
+type exitRecorder struct {
+	buf   *syncBuffer
+	calls []string
+	codes []int
+}
+
+func (r *exitRecorder) hook(name string) func() {
+	return func() {
+		r.calls = append(r.calls, name)
+		if len(r.buf.lines()) == 0 {
+			r.calls = append(r.calls, name+" before write")
+		}
+	}
+}
+
+func (r *exitRecorder) exit(code int) {
+	r.codes = append(r.codes, code)
+	r.calls = append(r.calls, "exit")
+}
+
+func newExitTestLogger(t *testing.T) (*Logger, *exitRecorder) {
+	t.Helper()
+	recorder := new(exitRecorder)
+	var s *Logger
+	s, recorder.buf = newTestLogger(t, InfoLevel, WithExitFunc(recorder.exit))
+	s.RegisterExitHook(recorder.hook("first"))
+	s.RegisterExitHook(recorder.hook("second"))
+	return s, recorder
+}
+
+func TestFatalRunsExitHooksAndExitFunc(t *testing.T) {
+	s, recorder := newExitTestLogger(t)
+	s.Fatal("fatal entry", String("key", "value"))
+
+	if entry := lastEntry(t, recorder.buf); entry["msg"] != "fatal entry" || entry["level"] != "fatal" {
+		t.Fatalf("unexpected entry %v", entry)
+	}
+	if want := []string{"first", "second", "exit"}; !reflect.DeepEqual(recorder.calls, want) {
+		t.Fatalf("calls = %q, want %q", recorder.calls, want)
+	}
+	if len(recorder.codes) != 1 || recorder.codes[0] != 1 {
+		t.Fatalf("exit codes = %v, want [1]", recorder.codes)
+	}
+}
+
+func TestPanicRunsExitHooksAndPanics(t *testing.T) {
+	s, recorder := newExitTestLogger(t)
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != "panic entry" {
+				t.Fatalf("recovered %v, want panic entry", recovered)
+			}
+		}()
+		s.Panic("panic entry")
+		t.Fatal("Panic returned")
+	}()
+
+	if entry := lastEntry(t, recorder.buf); entry["msg"] != "panic entry" || entry["level"] != "panic" {
+		t.Fatalf("unexpected entry %v", entry)
+	}
+	if want := []string{"first", "second"}; !reflect.DeepEqual(recorder.calls, want) {
+		t.Fatalf("calls = %q, want %q", recorder.calls, want)
+	}
+}
+
+func TestFatalWithoutEnabledInstancesCallsExitFunc(t *testing.T) {
+	s, recorder := newExitTestLogger(t)
+	s.SetLoggerEnabled("test", false)
+	s.Fatal("fatal entry")
+
+	if want := []string{"first", "first before write", "second", "second before write", "exit"}; !reflect.DeepEqual(recorder.calls, want) {
+		t.Fatalf("calls = %q, want %q", recorder.calls, want)
+	}
+}
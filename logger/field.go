@@ -314,37 +314,29 @@ func Inline(val zapcore.ObjectMarshaler) Field { return Field(zap.Inline(val)) }
 //goland:noinspection GoUnusedExportedFunction
 func Any(key string, value interface{}) Field { return Field(zap.Any(key, value)) }
 
-// Error is shorthand for the common idiom NamedError("error", err).
+// Error is shorthand for the common idiom NamedError("error", err). A nil error is logged as an empty string.
 func Error(err error) Field {
-	var errStr string
-	if err != nil {
-		errStr = err.Error()
+	if err == nil {
+		return Field(zap.String("error", ""))
 	}
-	return Field(zap.String("error", errStr))
+	return newErrorField("error", err)
 }
 
 // NamedError constructs a field that lazily stores err.Error() under the
-// provided key. Errors which also implement fmt.Formatter (like those produced
-// by github.com/pkg/errors) will also have their verbose representation stored
-// under key+"Verbose". If passed a nil error, the field is a no-op.
+// provided key, the error type under key+"_type", the messages of the wrapped
+// errors under key+"_chain" and the stack trace of errors that carry one (like
+// those produced by github.com/pkg/errors) under key+"_stack". Fields of the
+// extractors added with RegisterErrorExtractor are added as well. The error
+// value is kept so the IgnoreCancel methods can detect cancellation. If passed
+// a nil error, the field is a no-op.
 //
 // For the common case in which the key is simply "error", the Error function
 // is shorter and less repetitive.
 //
 //goland:noinspection GoUnusedExportedFunction
-func NamedError(key string, err error) Field { return Field(zap.NamedError(key, err)) }
-
-func fieldsContainContextCancelled(fields ...Field) bool {
-	//for i := range fields {
-	//	if fields[i].Type == zapcore.ErrorType {
-	//		fieldErr, fieldIsError := fields[i].Interface.(error)
-	//		if fieldIsError && fieldErr != nil && strings.Contains(fieldErr.Error(), "context canceled") {
-	//			return true
-	//		}
-	//	}
-	//	if fields[i].Type == zapcore.StringType && strings.Contains(fields[i].String, "context canceled") {
-	//		return true
-	//	}
-	//}
-	return false
+func NamedError(key string, err error) Field {
+	if err == nil {
+		return Skip()
+	}
+	return newErrorField(key, err)
 }
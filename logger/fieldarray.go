@@ -0,0 +1,403 @@
+package logger
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Array constructs a field with the given key and ArrayMarshaler. It provides
+// a flexible, but still type-safe and efficient, way to add array-like types
+// to the logging context. The struct's MarshalLogArray method is called lazily.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Array(key string, val zapcore.ArrayMarshaler) Field { return Field(zap.Array(key, val)) }
+
+// Bools constructs a field that carries a slice of bools.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Bools(key string, bs []bool) Field { return Field(zap.Bools(key, bs)) }
+
+// ByteStrings constructs a field that carries a slice of []byte, each of which
+// must be UTF-8 encoded text.
+//
+//goland:noinspection GoUnusedExportedFunction
+func ByteStrings(key string, bss [][]byte) Field { return Field(zap.ByteStrings(key, bss)) }
+
+// Complex128s constructs a field that carries a slice of complex numbers.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Complex128s(key string, nums []complex128) Field { return Field(zap.Complex128s(key, nums)) }
+
+// Complex64s constructs a field that carries a slice of complex numbers.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Complex64s(key string, nums []complex64) Field { return Field(zap.Complex64s(key, nums)) }
+
+// Durations constructs a field that carries a slice of time.Durations.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Durations(key string, ds []time.Duration) Field { return Field(zap.Durations(key, ds)) }
+
+// Float64s constructs a field that carries a slice of floats.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Float64s(key string, nums []float64) Field { return Field(zap.Float64s(key, nums)) }
+
+// Float32s constructs a field that carries a slice of floats.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Float32s(key string, nums []float32) Field { return Field(zap.Float32s(key, nums)) }
+
+// Ints constructs a field that carries a slice of integers.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Ints(key string, nums []int) Field { return Field(zap.Ints(key, nums)) }
+
+// Int64s constructs a field that carries a slice of integers.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Int64s(key string, nums []int64) Field { return Field(zap.Int64s(key, nums)) }
+
+// Int32s constructs a field that carries a slice of integers.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Int32s(key string, nums []int32) Field { return Field(zap.Int32s(key, nums)) }
+
+// Int16s constructs a field that carries a slice of integers.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Int16s(key string, nums []int16) Field { return Field(zap.Int16s(key, nums)) }
+
+// Int8s constructs a field that carries a slice of integers.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Int8s(key string, nums []int8) Field { return Field(zap.Int8s(key, nums)) }
+
+// Strings constructs a field that carries a slice of strings.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Strings(key string, ss []string) Field { return Field(zap.Strings(key, ss)) }
+
+// Times constructs a field that carries a slice of time.Times.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Times(key string, ts []time.Time) Field { return Field(zap.Times(key, ts)) }
+
+// Uints constructs a field that carries a slice of unsigned integers.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Uints(key string, nums []uint) Field { return Field(zap.Uints(key, nums)) }
+
+// Uint64s constructs a field that carries a slice of unsigned integers.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Uint64s(key string, nums []uint64) Field { return Field(zap.Uint64s(key, nums)) }
+
+// Uint32s constructs a field that carries a slice of unsigned integers.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Uint32s(key string, nums []uint32) Field { return Field(zap.Uint32s(key, nums)) }
+
+// Uint16s constructs a field that carries a slice of unsigned integers.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Uint16s(key string, nums []uint16) Field { return Field(zap.Uint16s(key, nums)) }
+
+// Uint8s constructs a field that carries a slice of unsigned integers.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Uint8s(key string, nums []uint8) Field { return Field(zap.Uint8s(key, nums)) }
+
+// Uintptrs constructs a field that carries a slice of pointer addresses.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Uintptrs(key string, us []uintptr) Field { return Field(zap.Uintptrs(key, us)) }
+
+// Errors constructs a field that carries a slice of errors. Each error is
+// encoded as an object with the fields described on NamedError under the key
+// "error". Nil errors are skipped.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Errors(key string, errs []error) Field {
+	return Array(key, zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, err := range errs {
+			if err == nil {
+				continue
+			}
+			if appendErr := arr.AppendObject(errorField{key: "error", err: err}); appendErr != nil {
+				return appendErr
+			}
+		}
+		return nil
+	}))
+}
+
+// Objects constructs a field with the given key, holding a list of the
+// provided objects that can be marshaled by zap.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Objects[T zapcore.ObjectMarshaler](key string, values []T) Field {
+	return Field(zap.Objects(key, values))
+}
+
+// ObjectValues is like Objects but for values whose pointer implements
+// zapcore.ObjectMarshaler.
+//
+//goland:noinspection GoUnusedExportedFunction
+func ObjectValues[T any, P zap.ObjectMarshalerPtr[T]](key string, values []T) Field {
+	return Field(zap.ObjectValues[T, P](key, values))
+}
+
+// Stringers constructs a field with the given key, holding a list of the
+// output provided by the value's String method.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Stringers[T fmt.Stringer](key string, values []T) Field {
+	return Field(zap.Stringers(key, values))
+}
+
+// Dict constructs a field containing the provided key-value pairs as a nested
+// object.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Dict(key string, fields ...Field) Field {
+	return Object(key, fieldsMarshaler(fields))
+}
+
+// Group constructs a field that nests fields in an object under key. With an
+// empty key the fields are added inline to the enclosing object instead.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Group(key string, fields ...Field) Field {
+	if key == "" {
+		return Inline(fieldsMarshaler(fields))
+	}
+	return Object(key, fieldsMarshaler(fields))
+}
+
+// Slice constructs a field that carries a slice of any element type without
+// reflection for the common ones. Elements implementing
+// zapcore.ObjectMarshaler or zapcore.ArrayMarshaler are encoded as objects and
+// arrays, errors and fmt.Stringers as strings ("<nil>" for a nil pointer, like
+// zap's Error and Stringer fields) and anything else with the encoder's
+// reflection fallback.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Slice[T any](key string, values []T) Field {
+	return Array(key, sliceMarshaler[T](values))
+}
+
+// Map constructs a field that carries a map as a nested object. Keys are
+// formatted with fmt.Sprint and sorted so the output is stable, values are
+// encoded like the elements of Slice. If two keys format the same, like 1 and
+// "1" in a map[any]V, every key is formatted with %#v instead so no value is
+// lost.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Map[K comparable, V any](key string, values map[K]V) Field {
+	return Object(key, mapMarshaler[K, V](values))
+}
+
+// fieldsMarshaler adds fields to an object.
+type fieldsMarshaler []Field
+
+func (fs fieldsMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for i := range fs {
+		zapcore.Field(fs[i]).AddTo(enc)
+	}
+	return nil
+}
+
+type sliceMarshaler[T any] []T
+
+func (s sliceMarshaler[T]) MarshalLogArray(arr zapcore.ArrayEncoder) error {
+	for i := range s {
+		if err := appendValue(arr, s[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type mapMarshaler[K comparable, V any] map[K]V
+
+func (m mapMarshaler[K, V]) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys, values, collided := m.formatKeys("%v")
+	if collided {
+		keys, values, _ = m.formatKeys("%#v")
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := addValue(enc, k, values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatKeys formats the keys of m with format. collided is set if two keys have the same formatted key.
+func (m mapMarshaler[K, V]) formatKeys(format string) (keys []string, values map[string]V, collided bool) {
+	keys = make([]string, 0, len(m))
+	values = make(map[string]V, len(m))
+	for k, v := range m {
+		formatted := fmt.Sprintf(format, k)
+		if _, exists := values[formatted]; exists {
+			collided = true
+			continue
+		}
+		keys = append(keys, formatted)
+		values[formatted] = v
+	}
+	return
+}
+
+// appendValue appends value to arr with the most specific method of the encoder.
+func appendValue(arr zapcore.ArrayEncoder, value interface{}) error {
+	switch val := value.(type) {
+	case nil:
+		return arr.AppendReflected(nil)
+	case string:
+		arr.AppendString(val)
+	case bool:
+		arr.AppendBool(val)
+	case int:
+		arr.AppendInt(val)
+	case int64:
+		arr.AppendInt64(val)
+	case int32:
+		arr.AppendInt32(val)
+	case int16:
+		arr.AppendInt16(val)
+	case int8:
+		arr.AppendInt8(val)
+	case uint:
+		arr.AppendUint(val)
+	case uint64:
+		arr.AppendUint64(val)
+	case uint32:
+		arr.AppendUint32(val)
+	case uint16:
+		arr.AppendUint16(val)
+	case uint8:
+		arr.AppendUint8(val)
+	case uintptr:
+		arr.AppendUintptr(val)
+	case float64:
+		arr.AppendFloat64(val)
+	case float32:
+		arr.AppendFloat32(val)
+	case time.Time:
+		arr.AppendTime(val)
+	case time.Duration:
+		arr.AppendDuration(val)
+	case zapcore.ObjectMarshaler:
+		return arr.AppendObject(val)
+	case zapcore.ArrayMarshaler:
+		return arr.AppendArray(val)
+	case error:
+		str, err := errorString(val)
+		if err != nil {
+			return err
+		}
+		arr.AppendString(str)
+	case fmt.Stringer:
+		str, err := stringerString(val)
+		if err != nil {
+			return err
+		}
+		arr.AppendString(str)
+	default:
+		return arr.AppendReflected(val)
+	}
+	return nil
+}
+
+// addValue adds value to enc at key with the most specific method of the encoder.
+func addValue(enc zapcore.ObjectEncoder, key string, value interface{}) error {
+	switch val := value.(type) {
+	case nil:
+		return enc.AddReflected(key, nil)
+	case string:
+		enc.AddString(key, val)
+	case bool:
+		enc.AddBool(key, val)
+	case int:
+		enc.AddInt(key, val)
+	case int64:
+		enc.AddInt64(key, val)
+	case int32:
+		enc.AddInt32(key, val)
+	case int16:
+		enc.AddInt16(key, val)
+	case int8:
+		enc.AddInt8(key, val)
+	case uint:
+		enc.AddUint(key, val)
+	case uint64:
+		enc.AddUint64(key, val)
+	case uint32:
+		enc.AddUint32(key, val)
+	case uint16:
+		enc.AddUint16(key, val)
+	case uint8:
+		enc.AddUint8(key, val)
+	case uintptr:
+		enc.AddUintptr(key, val)
+	case float64:
+		enc.AddFloat64(key, val)
+	case float32:
+		enc.AddFloat32(key, val)
+	case time.Time:
+		enc.AddTime(key, val)
+	case time.Duration:
+		enc.AddDuration(key, val)
+	case zapcore.ObjectMarshaler:
+		return enc.AddObject(key, val)
+	case zapcore.ArrayMarshaler:
+		return enc.AddArray(key, val)
+	case error:
+		str, err := errorString(val)
+		if err != nil {
+			return err
+		}
+		enc.AddString(key, str)
+	case fmt.Stringer:
+		str, err := stringerString(val)
+		if err != nil {
+			return err
+		}
+		enc.AddString(key, str)
+	default:
+		return enc.AddReflected(key, val)
+	}
+	return nil
+}
+
+// errorString returns err.Error(). Like zap's Error field a nil pointer whose Error method panics is "<nil>" and any
+// other panic is returned as an error.
+func errorString(err error) (str string, panicErr error) {
+	defer recoverNilString(err, &str, &panicErr)
+	return err.Error(), nil
+}
+
+// stringerString is errorString for a fmt.Stringer.
+func stringerString(stringer fmt.Stringer) (str string, panicErr error) {
+	defer recoverNilString(stringer, &str, &panicErr)
+	return stringer.String(), nil
+}
+
+func recoverNilString(value interface{}, str *string, panicErr *error) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr && v.IsNil() {
+		*str = "<nil>"
+		return
+	}
+	*panicErr = fmt.Errorf("PANIC=%v", recovered)
+}
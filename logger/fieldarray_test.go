@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"testing"
+)
+
+type nilPointerError struct {
+	msg string
+}
+
+func (e *nilPointerError) Error() string {
+	return e.msg
+}
+
+type nilPointerStringer struct {
+	name string
+}
+
+func (s *nilPointerStringer) String() string {
+	return s.name
+}
+
+func TestSliceEncodesNilPointers(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	s.Info("entry",
+		Slice("errors", []*nilPointerError{nil, {msg: "boom"}}),
+		Slice("stringers", []*nilPointerStringer{nil}),
+		Map("values", map[string]error{"missing": (*nilPointerError)(nil)}),
+	)
+
+	entry := lastEntry(t, buf)
+	errs := entry["errors"].([]interface{})
+	if errs[0] != "<nil>" || errs[1] != "boom" {
+		t.Fatalf("errors = %v", errs)
+	}
+	if stringers := entry["stringers"].([]interface{}); stringers[0] != "<nil>" {
+		t.Fatalf("stringers = %v", stringers)
+	}
+	if values := entry["values"].(map[string]interface{}); values["missing"] != "<nil>" {
+		t.Fatalf("values = %v", values)
+	}
+}
+
+func TestMapKeysThatFormatTheSame(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	s.Info("entry",
+		Map("distinct", map[int]string{1: "a", 2: "b"}),
+		Map("colliding", map[interface{}]string{1: "int", "1": "string"}),
+	)
+
+	entry := lastEntry(t, buf)
+	if distinct := entry["distinct"].(map[string]interface{}); distinct["1"] != "a" || distinct["2"] != "b" {
+		t.Fatalf("distinct = %v", distinct)
+	}
+	colliding := entry["colliding"].(map[string]interface{})
+	if len(colliding) != 2 || colliding["1"] != "int" || colliding[`"1"`] != "string" {
+		t.Fatalf("colliding = %v", colliding)
+	}
+}
@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// fieldTransformer is the callback of the field walker shared by the redaction and sanitization cores. The walker
+// finds the strings and byte slices of a field, including those nested in objects, arrays, Stringers and errors, and
+// hands them to the transformer.
+type fieldTransformer interface {
+	// transformLeaf returns the replacement of a string or []byte value, of the same type.
+	transformLeaf(value interface{}) interface{}
+	// transformEntry is called for every key of a nested object before its value is walked. It returns the
+	// replacement of the value and whether the key is kept, or handled false to walk the value.
+	transformEntry(key string, value interface{}) (replacement interface{}, keep bool, handled bool)
+}
+
+// walkField returns field with the strings and byte slices it holds replaced by t.
+func walkField(t fieldTransformer, field zapcore.Field) zapcore.Field {
+	switch field.Type {
+	case zapcore.StringType:
+		field.String = t.transformLeaf(field.String).(string)
+	case zapcore.ByteStringType:
+		value := string(field.Interface.([]byte))
+		if transformed := t.transformLeaf(value).(string); transformed != value {
+			return zap.String(field.Key, transformed)
+		}
+	case zapcore.BinaryType:
+		field.Interface = t.transformLeaf(field.Interface.([]byte))
+	case zapcore.StringerType, zapcore.ErrorType:
+		// encoded by the nested object path so an error's verbose form is kept
+		enc := zapcore.NewMapObjectEncoder()
+		field.AddTo(enc)
+		return walkedFieldOf(t, field.Key, enc.Fields)
+	case zapcore.ObjectMarshalerType:
+		return zap.Object(field.Key, walkedObject{t: t, inner: field.Interface.(zapcore.ObjectMarshaler)})
+	case zapcore.InlineMarshalerType:
+		field.Interface = walkedObject{t: t, inner: field.Interface.(zapcore.ObjectMarshaler)}
+	case zapcore.ArrayMarshalerType:
+		enc := zapcore.NewMapObjectEncoder()
+		_ = enc.AddArray(field.Key, field.Interface.(zapcore.ArrayMarshaler))
+		return zap.Reflect(field.Key, walkValue(t, enc.Fields[field.Key]))
+	case zapcore.ReflectType:
+		return zap.Reflect(field.Key, walkValue(t, jsonRoundTrip(field.Interface)))
+	}
+	return field
+}
+
+// walkedFieldOf returns a field for the walked values a field added to a map encoder.
+func walkedFieldOf(t fieldTransformer, key string, values map[string]interface{}) zapcore.Field {
+	walked := walkMap(t, values)
+	if len(walked) == 1 {
+		if value, found := walked[key]; found {
+			return zap.Any(key, value)
+		}
+	}
+	return zap.Inline(mapObject(walked))
+}
+
+func walkValue(t fieldTransformer, value interface{}) interface{} {
+	switch val := value.(type) {
+	case string, []byte:
+		return t.transformLeaf(val)
+	case map[string]interface{}:
+		return walkMap(t, val)
+	case []interface{}:
+		walked := make([]interface{}, 0, len(val))
+		for _, elem := range val {
+			walked = append(walked, walkValue(t, elem))
+		}
+		return walked
+	default:
+		return value
+	}
+}
+
+func walkMap(t fieldTransformer, values map[string]interface{}) map[string]interface{} {
+	walked := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if replacement, keep, handled := t.transformEntry(key, value); handled {
+			if keep {
+				walked[key] = replacement
+			}
+			continue
+		}
+		walked[key] = walkValue(t, value)
+	}
+	return walked
+}
+
+// walkedObject marshals inner to a map, walks it and adds the result to the encoder.
+type walkedObject struct {
+	t     fieldTransformer
+	inner zapcore.ObjectMarshaler
+}
+
+func (o walkedObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	values := zapcore.NewMapObjectEncoder()
+	if err := o.inner.MarshalLogObject(values); err != nil {
+		return err
+	}
+	return mapObject(walkMap(o.t, values.Fields)).MarshalLogObject(enc)
+}
@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"flag"
+	"go.uber.org/zap/zapcore"
+)
+
+// loggerFlags holds the values of the flags added by RegisterFlags until they are applied by StartTask.
+type loggerFlags struct {
+	level      Level
+	console    bool
+	jsonStdout bool
+	file       bool
+	fileLevel  Level
+}
+
+// RegisterFlags adds -log-level, -log-console, -log-json-stdout, -log-file and -log-file-level to fs. The parsed values
+// configure the console, json stdout and file loggers when StartTask is called, so parse fs before StartTask.
+// example:
+//
+//	logger.Instance().RegisterFlags(flag.CommandLine)
+//	flag.Parse()
+//	logger.Instance().StartTask()
+func (s *Logger) RegisterFlags(fs *flag.FlagSet) {
+	flags := &loggerFlags{
+		level:     InfoLevel,
+		fileLevel: ErrorLevel,
+	}
+	fs.Var(&flags.level, "log-level", "level of the console and json stdout loggers")
+	fs.BoolVar(&flags.console, "log-console", false, "log to the console")
+	fs.BoolVar(&flags.jsonStdout, "log-json-stdout", false, "log json to stdout")
+	fs.BoolVar(&flags.file, "log-file", false, "log to a file next to the executable")
+	fs.Var(&flags.fileLevel, "log-file-level", "level of the file logger")
+
+	s.startMutex.Lock()
+	defer s.startMutex.Unlock()
+	s.flags = flags
+}
+
+// apply configures the instances created by StartTask.
+func (f *loggerFlags) apply(cfg *loggerConfig) {
+	cfg.instances[debugConsoleKey].level.SetLevel(zapcore.Level(f.level))
+	cfg.enabled[debugConsoleKey] = f.console
+	cfg.instances[jsonStdoutKey].level.SetLevel(zapcore.Level(f.level))
+	cfg.enabled[jsonStdoutKey] = f.jsonStdout
+	cfg.instances[fileKey].level.SetLevel(zapcore.Level(f.fileLevel))
+	cfg.enabled[fileKey] = f.file
+}
@@ -0,0 +1,247 @@
+package logger
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultFlightRecorderEntries = 1000
+
+// FlightRecorderOptions configures the in-memory flight recorder enabled with WithFlightRecorder.
+type FlightRecorderOptions struct {
+	// Entries is the number of most recent entries kept. Defaults to 1000.
+	Entries int
+	// Path is a file the recorded entries are appended to as JSON lines when dumped. If empty they are written to
+	// the file logger, or to every enabled LogInstance if the file logger is not enabled.
+	Path string
+}
+
+// WithFlightRecorder makes StartTask keep the most recent Debug and above entries in memory regardless of the levels
+// of the LogInstances. The recorder is not an instance, IsLevelEnabled and Check still only report the levels of the
+// instances. The recorded entries are dumped when an entry at Error or above is logged, before that entry is
+// written and whether or not an instance accepts it, so also when task.HandlePanic recovers, or when
+// DumpFlightRecorder is called. Dumped entries are marked with a "flight_recorder" field and removed from the
+// recorder.
+// example: logger.Instance().StartTask(logger.WithFlightRecorder(logger.FlightRecorderOptions{Entries: 500}))
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithFlightRecorder(recorderOptions FlightRecorderOptions) LoggingOption {
+	return func(o *Options) {
+		o.flightRecorderOptions = recorderOptions
+		o.flightRecorderEnabled = true
+	}
+}
+
+// DumpFlightRecorder writes the entries recorded since the last dump, preceded by an entry with reason. It does
+// nothing if the flight recorder is not enabled or has no entries.
+func (s *Logger) DumpFlightRecorder(reason string) {
+	if recorder := s.config().flightRecorder; recorder != nil {
+		recorder.dump(reason)
+	}
+}
+
+type flightRecorderEntry struct {
+	ent    zapcore.Entry
+	fields []zapcore.Field
+}
+
+// flightRecorderRing holds the recorded entries. It is shared by the flightRecorderCore of every compiled config and
+// the cores derived from it with With.
+type flightRecorderRing struct {
+	logger *Logger
+	opts   FlightRecorderOptions
+
+	mutex   sync.Mutex // protects entries and next
+	entries []flightRecorderEntry
+	next    int
+}
+
+func newFlightRecorderRing(logger *Logger, recorderOpts FlightRecorderOptions) *flightRecorderRing {
+	if recorderOpts.Entries <= 0 {
+		recorderOpts.Entries = defaultFlightRecorderEntries
+	}
+	return &flightRecorderRing{
+		logger:  logger,
+		opts:    recorderOpts,
+		entries: make([]flightRecorderEntry, 0, recorderOpts.Entries),
+	}
+}
+
+// flightRecorderCore records the Debug and above entries checked by the dispatch logger in a ring buffer.
+type flightRecorderCore struct {
+	fields []zapcore.Field
+	ring   *flightRecorderRing
+}
+
+func (c *flightRecorderCore) Enabled(level zapcore.Level) bool {
+	return level >= zapcore.DebugLevel
+}
+
+func (c *flightRecorderCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &flightRecorderCore{
+		fields: make([]zapcore.Field, 0, len(c.fields)+len(fields)),
+		ring:   c.ring,
+	}
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return clone
+}
+
+func (c *flightRecorderCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *flightRecorderCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	entry := flightRecorderEntry{
+		ent:    ent,
+		fields: make([]zapcore.Field, 0, len(c.fields)+len(fields)),
+	}
+	entry.fields = append(entry.fields, c.fields...)
+	entry.fields = append(entry.fields, fields...)
+	if ent.Level >= zapcore.ErrorLevel {
+		// the entry itself is written by the instances, it is recorded as context for the next dump
+		c.ring.dump(ent.Level.String() + " logged")
+	}
+	c.ring.add(entry)
+	return nil
+}
+
+func (c *flightRecorderCore) Sync() error {
+	return nil
+}
+
+// record adds an entry that no LogInstance accepted, so it was not checked by the dispatch logger, with the
+// Logger.With fields of logger. Lazy fields are kept as they are and only computed if the entry is dumped. Like
+// flightRecorderCore.Write it dumps the recorded entries first if the entry is at Error or above.
+func (r *flightRecorderRing) record(logger *Logger, level zapcore.Level, msg string, fields []Field, contextFields []Field) {
+	if level < zapcore.DebugLevel {
+		return
+	}
+	if level >= zapcore.ErrorLevel {
+		r.dump(level.String() + " logged")
+	}
+	entry := flightRecorderEntry{
+		ent: zapcore.Entry{
+			Level:   level,
+			Time:    time.Now(),
+			Message: msg,
+		},
+		fields: make([]zapcore.Field, 0, len(logger.withFields)+len(fields)+len(contextFields)),
+	}
+	for _, field := range logger.withFields {
+		entry.fields = append(entry.fields, zapcore.Field(field))
+	}
+	for _, field := range logger.resolveFields(msg, fields, contextFields) {
+		entry.fields = append(entry.fields, zapcore.Field(field))
+	}
+	r.add(entry)
+}
+
+func (r *flightRecorderRing) add(entry flightRecorderEntry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.entries) < cap(r.entries) {
+		r.entries = append(r.entries, entry)
+		return
+	}
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+}
+
+// take removes and returns the recorded entries oldest first.
+func (r *flightRecorderRing) take() (entries []flightRecorderEntry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	entries = make([]flightRecorderEntry, 0, len(r.entries))
+	entries = append(entries, r.entries[r.next:]...)
+	entries = append(entries, r.entries[:r.next]...)
+	r.entries = r.entries[:0]
+	r.next = 0
+	return
+}
+
+// dump writes the recorded entries to the configured file, the file logger or every enabled LogInstance.
+func (r *flightRecorderRing) dump(reason string) {
+	entries := r.take()
+	if len(entries) == 0 {
+		return
+	}
+	header := flightRecorderEntry{
+		ent: zapcore.Entry{
+			Level:   zapcore.InfoLevel,
+			Time:    time.Now(),
+			Message: "flight recorder dump",
+		},
+		fields: []zapcore.Field{zap.String("reason", reason), zap.Int("entries", len(entries))},
+	}
+	entries = append([]flightRecorderEntry{header}, entries...)
+
+	if r.opts.Path != "" {
+		if err := r.dumpToFile(entries); err != nil {
+			r.logger.ErrorInLoggerWriter("flight recorder dump to %s failed: %v", r.opts.Path, err)
+		}
+		return
+	}
+
+	cfg := r.logger.config()
+	var cores []zapcore.Core
+	if fileInstance := cfg.instances[fileKey]; fileInstance != nil && cfg.enabled[fileKey] {
+		cores = append(cores, fileInstance.core)
+	} else {
+		for key, logInstance := range cfg.instances {
+			if cfg.enabled[key] {
+				cores = append(cores, logInstance.core)
+			}
+		}
+	}
+	for _, entry := range entries {
+		for _, core := range cores {
+			// Write bypasses the level of the instance
+			_ = core.Write(entry.ent, entry.markedFields())
+		}
+	}
+	for _, core := range cores {
+		_ = core.Sync()
+	}
+}
+
+// markedFields returns the fields of the entry with the "flight_recorder" field that marks dumped entries.
+func (e flightRecorderEntry) markedFields() []zapcore.Field {
+	return append(e.fields[:len(e.fields):len(e.fields)], zap.Bool("flight_recorder", true))
+}
+
+func (r *flightRecorderRing) dumpToFile(entries []flightRecorderEntry) (err error) {
+	var file *os.File
+	if file, err = os.OpenFile(r.opts.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644); err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeLevel = jsonLevelEncoder
+	encoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendString(t.UTC().Format(time.RFC3339Nano))
+	}
+	// the recorded entries are not redacted yet, instances redact in their own core
+	core := &redactCore{
+		Core:         zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), file, zapcore.DebugLevel),
+		redaction:    &r.logger.redaction,
+		pseudonymKey: &r.logger.pseudonymKey,
+	}
+	for _, entry := range entries {
+		if err = core.Write(entry.ent, entry.markedFields()); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFlightRecorderDoesNotEnableDebug(t *testing.T) {
+	s, _ := newTestLogger(t, InfoLevel, WithFlightRecorder(FlightRecorderOptions{}))
+	if s.IsLevelEnabled(DebugLevel) {
+		t.Fatal("IsLevelEnabled(DebugLevel) with every instance at Info")
+	}
+	if s.Check(DebugLevel, "debug") != nil {
+		t.Fatal("Check(DebugLevel) with every instance at Info")
+	}
+}
+
+func TestFlightRecorderDumpsBeforeError(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel, WithFlightRecorder(FlightRecorderOptions{}))
+	s.With(String("component", "db")).Debug("query", Int("rows", 3))
+	s.Info("request")
+	s.Error("failed")
+
+	var messages []string
+	for _, entry := range buf.entries(t) {
+		message := entry["msg"].(string)
+		if entry["flight_recorder"] == true {
+			message = "dump:" + message
+		}
+		messages = append(messages, message)
+		if message == "dump:query" && (entry["component"] != "db" || entry["rows"] != float64(3)) {
+			t.Errorf("recorded entry lost its fields: %v", entry)
+		}
+	}
+	started := "dump:" + getTaskLogPrefix(taskName, "started")
+	want := []string{"request", "dump:flight recorder dump", started, "dump:query", "dump:request", "failed"}
+	if !reflect.DeepEqual(messages, want) {
+		t.Fatalf("messages = %q, want %q", messages, want)
+	}
+}
+
+func TestFlightRecorderPathDump(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recorder.log")
+	s, buf := newTestLogger(t, InfoLevel, WithFlightRecorder(FlightRecorderOptions{Path: path}))
+	s.Debug("debug")
+	s.Error("failed")
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		var entry map[string]interface{}
+		if err = json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSON entry %q: %v", line, err)
+		}
+		if entry["flight_recorder"] != true {
+			t.Errorf("dumped entry not marked: %s", line)
+		}
+		messages = append(messages, entry["msg"].(string))
+	}
+	if want := []string{"flight recorder dump", getTaskLogPrefix(taskName, "started"), "debug"}; !reflect.DeepEqual(messages, want) {
+		t.Fatalf("dumped messages = %q, want %q", messages, want)
+	}
+	if lines := buf.lines(); len(lines) != 1 || lastEntry(t, buf)["msg"] != "failed" {
+		t.Fatalf("instance entries = %q, want only the error", lines)
+	}
+}
+
+func TestFlightRecorderDumpsErrorNoInstanceAccepts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recorder.log")
+	s, buf := newTestLogger(t, InfoLevel, WithFlightRecorder(FlightRecorderOptions{Path: path}))
+	s.SetLoggerEnabled("test", false)
+	s.Debug("debug")
+	s.Error("failed")
+	s.DumpFlightRecorder("explicit")
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		var entry map[string]interface{}
+		if err = json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSON entry %q: %v", line, err)
+		}
+		messages = append(messages, entry["msg"].(string))
+	}
+	// the error is recorded after the dump it triggered, so the explicit dump only holds the error
+	want := []string{
+		"flight recorder dump", getTaskLogPrefix(taskName, "started"), "debug",
+		"flight recorder dump", "failed",
+	}
+	if !reflect.DeepEqual(messages, want) {
+		t.Fatalf("dumped messages = %q, want %q", messages, want)
+	}
+	if lines := buf.lines(); len(lines) != 0 {
+		t.Fatalf("disabled instance wrote %q", lines)
+	}
+}
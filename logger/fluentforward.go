@@ -0,0 +1,222 @@
+package logger
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go.uber.org/zap/zapcore"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFluentForwardDialTimeout  = 5 * time.Second
+	defaultFluentForwardWriteTimeout = 10 * time.Second
+	defaultFluentForwardAckTimeout   = 30 * time.Second
+)
+
+// FluentForwardOptions configures a sink added with AddFluentForwardLogger.
+type FluentForwardOptions struct {
+	// Network is "tcp" or "unix". Defaults to "tcp".
+	Network string
+	// Address is host:port for tcp or the socket path for unix.
+	Address string
+	// RequireAck sends a chunk id with every message and waits for the server to acknowledge it before the batch is
+	// considered delivered.
+	RequireAck bool
+
+	DialTimeout  time.Duration
+	WriteTimeout time.Duration
+	AckTimeout   time.Duration
+
+	// FlushInterval is the maximum time an entry waits before being sent. Defaults to 1 second.
+	FlushInterval time.Duration
+	// MaxBatchEntries is the maximum number of entries sent in one PackedForward message.
+	MaxBatchEntries int
+	// MaxRetries is the number of times a failed batch is resent, reconnecting before each attempt. Defaults to 3, a
+	// negative value disables retries.
+	MaxRetries int
+}
+
+// AddFluentForwardLogger adds a LogInstance at key that sends entries to a Fluentd or fluent-bit forward input using
+// the Forward protocol in PackedForward mode. The tag is productNameShort + "." + key so call this after StartTask
+// when using WithProductNameShort. Delivery errors are reported with ErrorInLoggerWriter.
+func (s *Logger) AddFluentForwardLogger(key string, fluentOpts FluentForwardOptions, newLevel Level, opts ...LoggingOption) {
+	s.addInstance(key, newLevel, func(level zapcore.LevelEnabler, addLoggerOpts *Options) (zapcore.Core, func() error) {
+		if fluentOpts.Network == "" {
+			fluentOpts.Network = "tcp"
+		}
+		if fluentOpts.DialTimeout <= 0 {
+			fluentOpts.DialTimeout = defaultFluentForwardDialTimeout
+		}
+		if fluentOpts.WriteTimeout <= 0 {
+			fluentOpts.WriteTimeout = defaultFluentForwardWriteTimeout
+		}
+		if fluentOpts.AckTimeout <= 0 {
+			fluentOpts.AckTimeout = defaultFluentForwardAckTimeout
+		}
+		if fluentOpts.MaxRetries == 0 {
+			fluentOpts.MaxRetries = defaultBatchMaxRetries
+		}
+
+		sink := &fluentForwardSink{
+			opts: fluentOpts,
+			tag:  s.config().options.productNameShort + "." + key,
+		}
+		sink.batch = newSinkBatchWriter(
+			key,
+			sink,
+			batchOptions{
+				maxEntries: fluentOpts.MaxBatchEntries,
+				interval:   fluentOpts.FlushInterval,
+				maxRetries: fluentOpts.MaxRetries,
+			},
+			addLoggerOpts,
+			func(err error) {
+				s.ErrorInLoggerWriter("fluent forward logger %s failed: %v", key, err)
+			},
+		)
+		return newRecordCore(sink, level), sink.batch.Close
+	}, opts...)
+}
+
+// fluentForwardSink encodes records as Forward protocol [time, record] entries and sends them in batches.
+type fluentForwardSink struct {
+	opts  FluentForwardOptions
+	tag   string
+	batch *batchWriter
+
+	connMutex sync.Mutex // protects conn and reader
+	conn      net.Conn
+	reader    *bufio.Reader
+}
+
+func (f *fluentForwardSink) writeRecord(ent zapcore.Entry, fields map[string]interface{}) error {
+	fields["level"] = encodedLevelName(ent.Level)
+	fields["msg"] = ent.Message
+	if ent.LoggerName != "" {
+		fields["logger"] = ent.LoggerName
+	}
+	if ent.Caller.Defined {
+		fields["caller"] = ent.Caller.TrimmedPath()
+	}
+	if ent.Stack != "" {
+		fields["stacktrace"] = ent.Stack
+	}
+
+	entry := appendMsgpackArrayHeader(nil, 2)
+	entry = appendMsgpackEventTime(entry, ent.Time)
+	entry = appendMsgpack(entry, fields)
+	f.batch.add(entry)
+	return nil
+}
+
+func (f *fluentForwardSink) Sync() error {
+	return f.batch.Sync()
+}
+
+// Close closes the connection. It is called by the batchWriter after the last send.
+func (f *fluentForwardSink) Close() (err error) {
+	f.connMutex.Lock()
+	defer f.connMutex.Unlock()
+	if f.conn != nil {
+		err = f.conn.Close()
+		f.conn = nil
+		f.reader = nil
+	}
+	return
+}
+
+// send writes one PackedForward message: [tag, entries, option]. It is only called by the batchWriter which
+// serializes calls.
+func (f *fluentForwardSink) send(batch [][]byte) (err error) {
+	f.connMutex.Lock()
+	defer f.connMutex.Unlock()
+
+	if f.conn == nil {
+		var conn net.Conn
+		if conn, err = net.DialTimeout(f.opts.Network, f.opts.Address, f.opts.DialTimeout); err != nil {
+			return
+		}
+		f.conn = conn
+		f.reader = bufio.NewReader(conn)
+	}
+	defer func() {
+		if err != nil {
+			// reconnect on the next attempt
+			_ = f.conn.Close()
+			f.conn = nil
+			f.reader = nil
+		}
+	}()
+
+	var entriesLen int
+	for _, entry := range batch {
+		entriesLen += len(entry)
+	}
+
+	optionLen := 1
+	var chunk string
+	if f.opts.RequireAck {
+		if chunk, err = newFluentForwardChunkID(); err != nil {
+			return
+		}
+		optionLen++
+	}
+
+	msg := make([]byte, 0, entriesLen+len(f.tag)+64)
+	msg = appendMsgpackArrayHeader(msg, 3)
+	msg = appendMsgpackString(msg, f.tag)
+	msg = appendMsgpackBinaryHeader(msg, entriesLen)
+	for _, entry := range batch {
+		msg = append(msg, entry...)
+	}
+	msg = appendMsgpackMapHeader(msg, optionLen)
+	msg = appendMsgpackString(msg, "size")
+	msg = appendMsgpackUint(msg, uint64(len(batch)))
+	if f.opts.RequireAck {
+		msg = appendMsgpackString(msg, "chunk")
+		msg = appendMsgpackString(msg, chunk)
+	}
+
+	if err = f.conn.SetWriteDeadline(time.Now().Add(f.opts.WriteTimeout)); err != nil {
+		return
+	}
+	if _, err = f.conn.Write(msg); err != nil {
+		return
+	}
+
+	if f.opts.RequireAck {
+		err = f.readAck(chunk)
+	}
+	return
+}
+
+func (f *fluentForwardSink) readAck(chunk string) (err error) {
+	if err = f.conn.SetReadDeadline(time.Now().Add(f.opts.AckTimeout)); err != nil {
+		return
+	}
+	var resp interface{}
+	if resp, err = readMsgpack(f.reader); err != nil {
+		return
+	}
+	respMap, ok := resp.(map[string]interface{})
+	if !ok {
+		return errors.New("fluent forward: unexpected ack response")
+	}
+	if ack, _ := respMap["ack"].(string); ack != chunk {
+		return fmt.Errorf("fluent forward: ack %q does not match chunk %q", ack, chunk)
+	}
+	return
+}
+
+func newFluentForwardChunkID() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,207 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fluentForwardServer is a local Forward protocol input that decodes PackedForward messages and acknowledges chunks.
+type fluentForwardServer struct {
+	listener net.Listener
+	ack      bool
+
+	mutex    sync.Mutex
+	tags     []string
+	records  []map[string]interface{}
+	times    []time.Time
+	accepted int
+}
+
+func newFluentForwardServer(t *testing.T, ack bool) *fluentForwardServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fluentForwardServer{listener: listener, ack: ack}
+	t.Cleanup(func() { _ = listener.Close() })
+	go server.serve(t)
+	return server
+}
+
+func (f *fluentForwardServer) serve(t *testing.T) {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		f.mutex.Lock()
+		f.accepted++
+		f.mutex.Unlock()
+		go f.handle(t, conn)
+	}
+}
+
+func (f *fluentForwardServer) handle(t *testing.T, conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	if !f.ack {
+		// fail every send by closing before the ack is read
+		return
+	}
+	r := bufio.NewReader(conn)
+	for {
+		msg, err := readMsgpack(r)
+		if err != nil {
+			return
+		}
+		fields, _ := msg.([]interface{})
+		if len(fields) != 3 {
+			t.Errorf("PackedForward message has %d elements, want 3", len(fields))
+			return
+		}
+		tag, _ := fields[0].(string)
+		entries, _ := fields[1].([]byte)
+		option, _ := fields[2].(map[string]interface{})
+
+		er := bufio.NewReader(bytes.NewReader(entries))
+		for {
+			entry, err := readMsgpack(er)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Errorf("decoding entry: %v", err)
+				return
+			}
+			pair, _ := entry.([]interface{})
+			eventTime, _ := pair[0].([]byte)
+			record, _ := pair[1].(map[string]interface{})
+			f.mutex.Lock()
+			f.tags = append(f.tags, tag)
+			f.records = append(f.records, record)
+			// fixext 8: type byte, seconds, nanoseconds
+			f.times = append(f.times, time.Unix(int64(binary.BigEndian.Uint32(eventTime[1:5])), int64(binary.BigEndian.Uint32(eventTime[5:9]))))
+			f.mutex.Unlock()
+		}
+
+		if chunk, found := option["chunk"].(string); found {
+			ack := appendMsgpackMapHeader(nil, 1)
+			ack = appendMsgpackString(ack, "ack")
+			ack = appendMsgpackString(ack, chunk)
+			if _, err = conn.Write(ack); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestFluentForwardDelivers(t *testing.T) {
+	server := newFluentForwardServer(t, true)
+	s, _ := newTestLogger(t, InfoLevel)
+	s.AddFluentForwardLogger("fluent", FluentForwardOptions{
+		Address:       server.listener.Addr().String(),
+		RequireAck:    true,
+		FlushInterval: time.Hour,
+	}, InfoLevel)
+
+	before := time.Now().Add(-time.Second)
+	s.Info("first", String("k", "v"), Int("n", 7))
+	s.Warn("second")
+	s.Sync()
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if len(server.records) != 2 {
+		t.Fatalf("received %d records, want 2", len(server.records))
+	}
+	if server.tags[0] != DefaultAppShortName+".fluent" {
+		t.Errorf("tag = %q", server.tags[0])
+	}
+	first := server.records[0]
+	if first["msg"] != "first" || first["level"] != "info" || first["k"] != "v" || first["n"] != int64(7) {
+		t.Errorf("first record = %v", first)
+	}
+	if server.records[1]["msg"] != "second" || server.records[1]["level"] != "warn" {
+		t.Errorf("second record = %v", server.records[1])
+	}
+	if server.times[0].Before(before) || server.times[0].After(time.Now()) {
+		t.Errorf("event time = %v", server.times[0])
+	}
+}
+
+func TestFluentForwardRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+		want       int
+	}{
+		{name: "default", maxRetries: 0, want: 1 + defaultBatchMaxRetries},
+		{name: "disabled", maxRetries: -1, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFluentForwardServer(t, false)
+			s, _ := newTestLogger(t, InfoLevel)
+			s.AddFluentForwardLogger("fluent", FluentForwardOptions{
+				Address:       server.listener.Addr().String(),
+				RequireAck:    true,
+				FlushInterval: time.Hour,
+				MaxRetries:    tt.maxRetries,
+			}, InfoLevel)
+			s.Info("entry")
+			s.Sync()
+
+			server.mutex.Lock()
+			defer server.mutex.Unlock()
+			if server.accepted != tt.want {
+				t.Fatalf("%d connections, want %d", server.accepted, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveLoggerStopsBatchWriter(t *testing.T) {
+	server := newFluentForwardServer(t, true)
+	s, _ := newTestLogger(t, InfoLevel)
+	// let the goroutines of the test logger start
+	time.Sleep(10 * time.Millisecond)
+	before := runtime.NumGoroutine()
+
+	s.AddFluentForwardLogger("fluent", FluentForwardOptions{
+		Address:    server.listener.Addr().String(),
+		RequireAck: true,
+	}, InfoLevel)
+	s.Info("entry")
+	s.RemoveLogger("fluent")
+
+	server.mutex.Lock()
+	received := len(server.records)
+	server.mutex.Unlock()
+	if received != 1 {
+		t.Fatalf("received %d records, want the entry flushed by RemoveLogger", received)
+	}
+	if _, found := s.InstanceMetrics("fluent"); found {
+		t.Fatal("instance still configured after RemoveLogger")
+	}
+	waitForGoroutines(t, before)
+}
+
+// waitForGoroutines fails the test if the number of goroutines does not drop back to want.
+func waitForGoroutines(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines, want %d", runtime.NumGoroutine(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,315 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap/zapcore"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultGelfChunkSize    = 1420
+	defaultGelfDialTimeout  = 5 * time.Second
+	defaultGelfWriteTimeout = 10 * time.Second
+
+	gelfMaxChunks = 128
+)
+
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// GelfOptions configures a sink added with AddGelfLogger.
+type GelfOptions struct {
+	// Network is "udp" or "tcp". Defaults to "udp".
+	Network string
+	// Address is the host:port of the Graylog GELF input.
+	Address string
+	// Host is the GELF host field. Defaults to os.Hostname().
+	Host string
+	// Compress gzips each UDP message. It is ignored for tcp which does not support compression.
+	Compress bool
+	// ChunkSize is the maximum UDP datagram size. Larger messages are split into GELF chunks. Defaults to 1420.
+	ChunkSize int
+
+	DialTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// FlushInterval is the maximum time an entry waits before being sent. Defaults to 1 second.
+	FlushInterval time.Duration
+	// MaxRetries is the number of times a failed batch is resent, reconnecting before each attempt. Defaults to 3, a
+	// negative value disables retries.
+	MaxRetries int
+}
+
+// AddGelfLogger adds a LogInstance at key that sends GELF 1.1 messages to Graylog over UDP (optionally gzipped and
+// chunked) or null delimited TCP. Delivery errors are reported with ErrorInLoggerWriter.
+func (s *Logger) AddGelfLogger(key string, gelfOpts GelfOptions, newLevel Level, opts ...LoggingOption) {
+	s.addInstance(key, newLevel, func(level zapcore.LevelEnabler, addLoggerOpts *Options) (zapcore.Core, func() error) {
+		if gelfOpts.Network == "" {
+			gelfOpts.Network = "udp"
+		}
+		if gelfOpts.Host == "" {
+			gelfOpts.Host, _ = os.Hostname()
+		}
+		if gelfOpts.ChunkSize <= 0 {
+			gelfOpts.ChunkSize = defaultGelfChunkSize
+		}
+		if gelfOpts.DialTimeout <= 0 {
+			gelfOpts.DialTimeout = defaultGelfDialTimeout
+		}
+		if gelfOpts.WriteTimeout <= 0 {
+			gelfOpts.WriteTimeout = defaultGelfWriteTimeout
+		}
+		if gelfOpts.MaxRetries == 0 {
+			gelfOpts.MaxRetries = defaultBatchMaxRetries
+		}
+
+		sink := &gelfSink{
+			opts: gelfOpts,
+		}
+		sink.batch = newSinkBatchWriter(
+			key,
+			sink,
+			batchOptions{
+				interval:   gelfOpts.FlushInterval,
+				maxRetries: gelfOpts.MaxRetries,
+			},
+			addLoggerOpts,
+			func(err error) {
+				s.ErrorInLoggerWriter("gelf logger %s failed: %v", key, err)
+			},
+		)
+		return newRecordCore(sink, level), sink.batch.Close
+	}, opts...)
+}
+
+// gelfSyslogLevel maps a Level to the syslog severity used by GELF.
+func gelfSyslogLevel(level zapcore.Level) int {
+	switch {
+	case level >= zapcore.FatalLevel:
+		return 0 // emergency
+	case level >= zapcore.PanicLevel:
+		return 1 // alert
+	case level >= zapcore.DPanicLevel:
+		return 2 // critical
+	case level >= zapcore.ErrorLevel:
+		return 3 // error
+	case level >= zapcore.WarnLevel:
+		return 4 // warning
+	case level >= zapcore.InfoLevel:
+		return 6 // informational
+	default:
+		return 7 // debug
+	}
+}
+
+// gelfSink encodes records as GELF 1.1 JSON messages and sends them in batches.
+type gelfSink struct {
+	opts  GelfOptions
+	batch *batchWriter
+
+	connMutex sync.Mutex // protects conn
+	conn      net.Conn
+}
+
+func (g *gelfSink) writeRecord(ent zapcore.Entry, fields map[string]interface{}) error {
+	msg := make(map[string]interface{}, len(fields)+8)
+	for k, v := range fields {
+		addGelfAdditionalField(msg, k, v)
+	}
+	msg["version"] = "1.1"
+	msg["host"] = g.opts.Host
+	msg["short_message"] = ent.Message
+	msg["timestamp"] = float64(ent.Time.UnixNano()) / float64(time.Second)
+	msg["level"] = gelfSyslogLevel(ent.Level)
+	if ent.Stack != "" {
+		msg["full_message"] = ent.Message + "\n" + ent.Stack
+	}
+	if ent.LoggerName != "" {
+		msg["_logger"] = ent.LoggerName
+	}
+	if ent.Caller.Defined {
+		msg["_caller"] = ent.Caller.TrimmedPath()
+	}
+
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	g.batch.add(encoded)
+	return nil
+}
+
+// addGelfAdditionalField adds a field with the GELF "_" prefix. GELF only allows string and number values so booleans
+// are written as "true" and "false" and nested objects and arrays are flattened to JSON strings.
+func addGelfAdditionalField(msg map[string]interface{}, key string, value interface{}) {
+	key = gelfFieldKey(key)
+	switch val := value.(type) {
+	case bool:
+		msg[key] = strconv.FormatBool(val)
+	case string,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, uintptr,
+		float32, float64:
+		msg[key] = val
+	case time.Time:
+		msg[key] = val.UTC().Format(time.RFC3339Nano)
+	case time.Duration:
+		msg[key] = val.Seconds()
+	case error:
+		msg[key] = val.Error()
+	case fmt.Stringer:
+		msg[key] = val.String()
+	default:
+		encoded, err := json.Marshal(val)
+		if err != nil {
+			msg[key] = fmt.Sprintf("%+v", val)
+			return
+		}
+		msg[key] = string(encoded)
+	}
+}
+
+// gelfFieldKey prefixes key with "_" and replaces characters GELF does not allow in additional field names.
+func gelfFieldKey(key string) string {
+	var sb strings.Builder
+	sb.WriteString("_")
+	for _, r := range key {
+		if r == '_' || r == '.' || r == '-' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	if sb.String() == "_id" {
+		// _id is reserved by Graylog
+		return "_id_"
+	}
+	return sb.String()
+}
+
+func (g *gelfSink) Sync() error {
+	return g.batch.Sync()
+}
+
+// Close closes the connection. It is called by the batchWriter after the last send.
+func (g *gelfSink) Close() (err error) {
+	g.connMutex.Lock()
+	defer g.connMutex.Unlock()
+	if g.conn != nil {
+		err = g.conn.Close()
+		g.conn = nil
+	}
+	return
+}
+
+// send writes each message in batch. It is only called by the batchWriter which serializes calls.
+func (g *gelfSink) send(batch [][]byte) (err error) {
+	g.connMutex.Lock()
+	defer g.connMutex.Unlock()
+
+	if g.conn == nil {
+		var conn net.Conn
+		if conn, err = net.DialTimeout(g.opts.Network, g.opts.Address, g.opts.DialTimeout); err != nil {
+			return
+		}
+		g.conn = conn
+	}
+	defer func() {
+		if err != nil && !errors.As(err, new(permanentSendError)) {
+			// reconnect on the next attempt
+			_ = g.conn.Close()
+			g.conn = nil
+		}
+	}()
+
+	if err = g.conn.SetWriteDeadline(time.Now().Add(g.opts.WriteTimeout)); err != nil {
+		return
+	}
+
+	if g.opts.Network == "tcp" {
+		var buf bytes.Buffer
+		for _, msg := range batch {
+			buf.Write(msg)
+			buf.WriteByte(0)
+		}
+		_, err = g.conn.Write(buf.Bytes())
+		return
+	}
+
+	// a message that cannot be sent is dropped on its own so the datagrams already sent are not resent with the batch
+	var dropped int
+	var dropErr error
+	for _, msg := range batch {
+		if err = g.sendDatagram(msg); err != nil {
+			if !errors.As(err, new(permanentSendError)) {
+				return
+			}
+			dropped++
+			dropErr = err
+			err = nil
+		}
+	}
+	if dropped > 0 {
+		err = permanentSendError{err: fmt.Errorf("gelf: dropped %d of %d messages: %w", dropped, len(batch), dropErr)}
+	}
+	return
+}
+
+func (g *gelfSink) sendDatagram(msg []byte) (err error) {
+	if g.opts.Compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err = zw.Write(msg); err != nil {
+			return
+		}
+		if err = zw.Close(); err != nil {
+			return
+		}
+		msg = buf.Bytes()
+	}
+
+	if len(msg) <= g.opts.ChunkSize {
+		_, err = g.conn.Write(msg)
+		return
+	}
+
+	// chunk header: magic (2) + message id (8) + sequence number (1) + sequence count (1)
+	const headerLen = 12
+	dataLen := g.opts.ChunkSize - headerLen
+	if dataLen <= 0 {
+		return permanentSendError{err: errors.New("gelf: chunk size too small")}
+	}
+	count := (len(msg) + dataLen - 1) / dataLen
+	if count > gelfMaxChunks {
+		return permanentSendError{err: fmt.Errorf("gelf: message of %d bytes needs %d chunks, the maximum is %d", len(msg), count, gelfMaxChunks)}
+	}
+
+	id := make([]byte, 8)
+	if _, err = rand.Read(id); err != nil {
+		return
+	}
+	chunk := make([]byte, 0, g.opts.ChunkSize)
+	for seq := 0; seq < count; seq++ {
+		end := (seq + 1) * dataLen
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunk = append(chunk[:0], gelfChunkMagic...)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(seq), byte(count))
+		chunk = append(chunk, msg[seq*dataLen:end]...)
+		if _, err = g.conn.Write(chunk); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,218 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gelfUDPServer is a local GELF UDP input that reassembles chunked and gzipped messages.
+type gelfUDPServer struct {
+	conn net.PacketConn
+
+	mutex    sync.Mutex
+	chunks   map[string][][]byte
+	messages []map[string]interface{}
+}
+
+func newGelfUDPServer(t *testing.T) *gelfUDPServer {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &gelfUDPServer{conn: conn, chunks: make(map[string][][]byte)}
+	t.Cleanup(func() { _ = conn.Close() })
+	go server.serve(t)
+	return server
+}
+
+func (g *gelfUDPServer) serve(t *testing.T) {
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := g.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		datagram := append([]byte(nil), buf[:n]...)
+		if bytes.HasPrefix(datagram, gelfChunkMagic) {
+			id, seq, count := string(datagram[2:10]), int(datagram[10]), int(datagram[11])
+			g.mutex.Lock()
+			if g.chunks[id] == nil {
+				g.chunks[id] = make([][]byte, count)
+			}
+			g.chunks[id][seq] = datagram[12:]
+			complete := true
+			for _, chunk := range g.chunks[id] {
+				complete = complete && chunk != nil
+			}
+			var msg []byte
+			if complete {
+				msg = bytes.Join(g.chunks[id], nil)
+				delete(g.chunks, id)
+			}
+			g.mutex.Unlock()
+			if !complete {
+				continue
+			}
+			datagram = msg
+		}
+		g.add(t, datagram)
+	}
+}
+
+func (g *gelfUDPServer) add(t *testing.T, msg []byte) {
+	if bytes.HasPrefix(msg, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(msg))
+		if err != nil {
+			t.Errorf("gzip: %v", err)
+			return
+		}
+		if msg, err = io.ReadAll(zr); err != nil {
+			t.Errorf("gzip: %v", err)
+			return
+		}
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(msg, &decoded); err != nil {
+		t.Errorf("invalid GELF message %q: %v", msg, err)
+		return
+	}
+	g.mutex.Lock()
+	g.messages = append(g.messages, decoded)
+	g.mutex.Unlock()
+}
+
+// waitMessages waits until n messages were received and returns them.
+func (g *gelfUDPServer) waitMessages(t *testing.T, n int) []map[string]interface{} {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		g.mutex.Lock()
+		messages := append([]map[string]interface{}(nil), g.messages...)
+		g.mutex.Unlock()
+		if len(messages) >= n || time.Now().After(deadline) {
+			return messages
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func randomHex(t *testing.T, n int) string {
+	t.Helper()
+	raw := make([]byte, n/2)
+	if _, err := rand.Read(raw); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(raw)
+}
+
+func TestGelfUDPChunkedCompressed(t *testing.T) {
+	server := newGelfUDPServer(t)
+	s, _ := newTestLogger(t, InfoLevel)
+	s.AddGelfLogger("gelf", GelfOptions{
+		Address:   server.conn.LocalAddr().String(),
+		Host:      "test-host",
+		Compress:  true,
+		ChunkSize: 200,
+	}, InfoLevel)
+
+	large := randomHex(t, 2000)
+	s.Warn("chunked", String("large", large), Bool("ok", true), Int("n", 3), String("id", "x"))
+	s.Sync()
+
+	messages := server.waitMessages(t, 1)
+	if len(messages) != 1 {
+		t.Fatalf("received %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+	want := map[string]interface{}{
+		"version":       "1.1",
+		"host":          "test-host",
+		"short_message": "chunked",
+		"level":         float64(4),
+		"_large":        large,
+		"_ok":           "true",
+		"_n":            float64(3),
+		"_id_":          "x",
+	}
+	for key, value := range want {
+		if msg[key] != value {
+			t.Errorf("%s = %v, want %v", key, msg[key], value)
+		}
+	}
+}
+
+func TestGelfUDPOversizedMessageDropped(t *testing.T) {
+	server := newGelfUDPServer(t)
+	s, _ := newTestLogger(t, InfoLevel)
+	s.AddGelfLogger("gelf", GelfOptions{
+		Address:       server.conn.LocalAddr().String(),
+		ChunkSize:     100,
+		FlushInterval: time.Hour,
+	}, InfoLevel)
+
+	// 128 chunks of 88 bytes hold about 11KB
+	s.Info("before")
+	s.Info("oversized", String("large", randomHex(t, 20000)))
+	s.Info("after")
+	s.Sync()
+
+	server.waitMessages(t, 2)
+	// give duplicates from a resent batch time to arrive
+	time.Sleep(100 * time.Millisecond)
+	messages := server.waitMessages(t, 2)
+	var got []string
+	for _, msg := range messages {
+		got = append(got, msg["short_message"].(string))
+	}
+	if strings.Join(got, ",") != "before,after" {
+		t.Fatalf("received %v, want [before after] once each", got)
+	}
+}
+
+func TestGelfTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+	received := make(chan map[string]interface{}, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		msg, err := bufio.NewReader(conn).ReadBytes(0)
+		if err != nil {
+			return
+		}
+		var decoded map[string]interface{}
+		_ = json.Unmarshal(msg[:len(msg)-1], &decoded)
+		received <- decoded
+	}()
+
+	s, _ := newTestLogger(t, InfoLevel)
+	s.AddGelfLogger("gelf", GelfOptions{Network: "tcp", Address: listener.Addr().String()}, InfoLevel)
+	s.Error("over tcp")
+	s.Sync()
+
+	select {
+	case msg := <-received:
+		if msg["short_message"] != "over tcp" || msg["level"] != float64(3) {
+			t.Fatalf("message = %v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message received")
+	}
+}
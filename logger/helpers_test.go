@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// syncBuffer is a bytes.Buffer safe for the background writers of async and network instances.
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+// lines returns the non-empty lines written so far.
+func (b *syncBuffer) lines() (lines []string) {
+	scanner := bufio.NewScanner(strings.NewReader(b.String()))
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return
+}
+
+// entries returns the JSON entries written so far.
+func (b *syncBuffer) entries(t *testing.T) (entries []map[string]interface{}) {
+	t.Helper()
+	for _, line := range b.lines() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSON entry %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
+// newTestLogger starts a Logger with a JSON LogInstance at key "test" writing to the returned buffer at level. The
+// built-in instances stay disabled.
+func newTestLogger(t *testing.T, level Level, opts ...LoggingOption) (*Logger, *syncBuffer) {
+	t.Helper()
+	s := NewLogger()
+	s.StartTask(opts...)
+	t.Cleanup(s.StopTask)
+	buf := new(syncBuffer)
+	s.AddLogger("test", buf, level)
+	return s, buf
+}
+
+// lastEntry returns the last JSON entry written to buf.
+func lastEntry(t *testing.T, buf *syncBuffer) map[string]interface{} {
+	t.Helper()
+	entries := buf.entries(t)
+	if len(entries) == 0 {
+		t.Fatal("no entries written")
+	}
+	return entries[len(entries)-1]
+}
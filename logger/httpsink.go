@@ -0,0 +1,224 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"io"
+	"net/http"
+	"time"
+)
+
+const defaultHTTPTimeout = 30 * time.Second
+
+// HTTPPayloadFormat selects one of the built-in request body templates for AddHTTPLogger.
+type HTTPPayloadFormat int
+
+//goland:noinspection GoUnusedConst
+const (
+	// HTTPPayloadNDJSON sends one JSON encoded entry per line (application/x-ndjson).
+	HTTPPayloadNDJSON HTTPPayloadFormat = iota
+	// HTTPPayloadJSONArray sends the entries as a JSON array (application/json).
+	HTTPPayloadJSONArray
+)
+
+// HTTPPayloadFunc builds a request body from a batch of JSON encoded entries. Each entry is a complete JSON object
+// without a trailing newline.
+type HTTPPayloadFunc func(entries [][]byte) (body []byte, contentType string, err error)
+
+// HTTPOptions configures a sink added with AddHTTPLogger.
+type HTTPOptions struct {
+	// URL of the collector.
+	URL string
+	// Method defaults to POST.
+	Method string
+	// Headers are added to every request.
+	Headers map[string]string
+	// BearerToken sets an Authorization: Bearer header.
+	BearerToken string
+	// Username and Password set basic auth.
+	Username string
+	Password string
+
+	// Format selects a built-in body template. It is ignored when Payload is set.
+	Format HTTPPayloadFormat
+	// Payload builds a custom request body.
+	Payload HTTPPayloadFunc
+	// Gzip compresses the request body and sets Content-Encoding: gzip.
+	Gzip bool
+
+	// Client defaults to an http.Client with Timeout.
+	Client  *http.Client
+	Timeout time.Duration
+
+	// MaxBatchEntries and MaxBatchBytes trigger a send when reached. FlushInterval is the maximum time an entry waits.
+	MaxBatchEntries int
+	MaxBatchBytes   int
+	FlushInterval   time.Duration
+	// QueueEntries bounds the in-memory queue. Entries logged while the queue is full are dropped and counted.
+	QueueEntries int
+
+	// MaxRetries is the number of times a failed batch is resent. Defaults to 3, a negative value disables retries.
+	// Server errors (5xx), 429 and transport errors are retried, other responses are not.
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// AddHTTPLogger adds a LogInstance at key that POSTs batches of JSON entries to an HTTP collector. Delivery errors are
+// reported with ErrorInLoggerWriter.
+func (s *Logger) AddHTTPLogger(key string, httpOpts HTTPOptions, newLevel Level, opts ...LoggingOption) {
+	s.addInstance(key, newLevel, func(level zapcore.LevelEnabler, addLoggerOpts *Options) (zapcore.Core, func() error) {
+		if httpOpts.Method == "" {
+			httpOpts.Method = http.MethodPost
+		}
+		if httpOpts.Payload == nil {
+			switch httpOpts.Format {
+			case HTTPPayloadJSONArray:
+				httpOpts.Payload = httpPayloadJSONArray
+			default:
+				httpOpts.Payload = httpPayloadNDJSON
+			}
+		}
+		if httpOpts.Timeout <= 0 {
+			httpOpts.Timeout = defaultHTTPTimeout
+		}
+		if httpOpts.Client == nil {
+			httpOpts.Client = &http.Client{Timeout: httpOpts.Timeout}
+		}
+		if httpOpts.MaxRetries == 0 {
+			httpOpts.MaxRetries = defaultBatchMaxRetries
+		}
+
+		sink := &httpSink{
+			opts: httpOpts,
+		}
+		sink.batch = newSinkBatchWriter(
+			key,
+			sink,
+			batchOptions{
+				maxEntries:   httpOpts.MaxBatchEntries,
+				maxBytes:     httpOpts.MaxBatchBytes,
+				interval:     httpOpts.FlushInterval,
+				queueEntries: httpOpts.QueueEntries,
+				maxRetries:   httpOpts.MaxRetries,
+				minBackoff:   httpOpts.MinBackoff,
+				maxBackoff:   httpOpts.MaxBackoff,
+			},
+			addLoggerOpts,
+			func(err error) {
+				s.ErrorInLoggerWriter("http logger %s failed: %v", key, err)
+			},
+		)
+
+		encoderConfig := zap.NewProductionEncoderConfig()
+		encoderConfig.EncodeLevel = jsonLevelEncoder
+		encoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString(t.UTC().Format(time.RFC3339Nano))
+		}
+
+		return zapcore.NewCore(
+			zapcore.NewJSONEncoder(encoderConfig),
+			sink,
+			level,
+		), sink.batch.Close
+	}, opts...)
+}
+
+func httpPayloadNDJSON(entries [][]byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		buf.Write(entry)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), "application/x-ndjson", nil
+}
+
+func httpPayloadJSONArray(entries [][]byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, entry := range entries {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(entry)
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), "application/json", nil
+}
+
+// httpSink is the zapcore.WriteSyncer for an HTTP LogInstance. Each Write is one encoded entry.
+type httpSink struct {
+	opts  HTTPOptions
+	batch *batchWriter
+}
+
+func (h *httpSink) Write(p []byte) (int, error) {
+	// the encoder reuses its buffer so the entry must be copied
+	trimmed := bytes.TrimSuffix(p, []byte(zapcore.DefaultLineEnding))
+	entry := make([]byte, len(trimmed))
+	copy(entry, trimmed)
+	h.batch.add(entry)
+	return len(p), nil
+}
+
+func (h *httpSink) Sync() error {
+	return h.batch.Sync()
+}
+
+// send POSTs one batch. It is only called by the batchWriter which serializes calls.
+func (h *httpSink) send(batch [][]byte) error {
+	body, contentType, err := h.opts.Payload(batch)
+	if err != nil {
+		return permanentSendError{err: err}
+	}
+
+	if h.opts.Gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err = zw.Write(body); err != nil {
+			return permanentSendError{err: err}
+		}
+		if err = zw.Close(); err != nil {
+			return permanentSendError{err: err}
+		}
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequest(h.opts.Method, h.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return permanentSendError{err: err}
+	}
+	req.Header.Set("Content-Type", contentType)
+	if h.opts.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range h.opts.Headers {
+		req.Header.Set(k, v)
+	}
+	if h.opts.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+h.opts.BearerToken)
+	} else if h.opts.Username != "" || h.opts.Password != "" {
+		req.SetBasicAuth(h.opts.Username, h.opts.Password)
+	}
+
+	resp, err := h.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	statusErr := fmt.Errorf("%s %s: unexpected status %s", h.opts.Method, h.opts.URL, resp.Status)
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return statusErr
+	}
+	return permanentSendError{err: statusErr}
+}
@@ -0,0 +1,256 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// httpCollector is a local HTTP log collector. It answers each request with the next status of statuses, and with
+// 200 once they are used up.
+type httpCollector struct {
+	server *httptest.Server
+
+	mutex    sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newHTTPCollector(t *testing.T, statuses ...int) *httpCollector {
+	t.Helper()
+	c := &httpCollector{statuses: statuses}
+	c.server = httptest.NewServer(http.HandlerFunc(c.handle))
+	t.Cleanup(c.server.Close)
+	return c
+}
+
+func (c *httpCollector) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.requests = append(c.requests, r)
+	c.bodies = append(c.bodies, body)
+	status := http.StatusOK
+	if len(c.statuses) > 0 {
+		status, c.statuses = c.statuses[0], c.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (c *httpCollector) received() (requests []*http.Request, bodies [][]byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append(requests, c.requests...), append(bodies, c.bodies...)
+}
+
+// entries decodes the entries of a request body in the format it was sent in.
+func (c *httpCollector) entries(t *testing.T, r *http.Request, body []byte) (entries []map[string]interface{}) {
+	t.Helper()
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	switch r.Header.Get("Content-Type") {
+	case "application/json":
+		if err := json.Unmarshal(body, &entries); err != nil {
+			t.Fatalf("invalid JSON array %q: %v", body, err)
+		}
+	case "application/x-ndjson":
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		for scanner.Scan() {
+			var entry map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+			}
+			entries = append(entries, entry)
+		}
+	default:
+		t.Fatalf("unexpected Content-Type %q", r.Header.Get("Content-Type"))
+	}
+	return
+}
+
+// captureStdout redirects os.Stdout to a file for the rest of the test and returns a func that reads what was written.
+// It must be called before StartTask, the JSON stdout instance keeps the os.Stdout it was created with.
+func captureStdout(t *testing.T) func() string {
+	t.Helper()
+	f, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = f
+	t.Cleanup(func() {
+		os.Stdout = stdout
+		_ = f.Close()
+	})
+	return func() string {
+		written, _ := os.ReadFile(f.Name())
+		return string(written)
+	}
+}
+
+func TestHTTPPayloadFormats(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      HTTPPayloadFormat
+		gzip        bool
+		contentType string
+	}{
+		{name: "ndjson", format: HTTPPayloadNDJSON, contentType: "application/x-ndjson"},
+		{name: "json array", format: HTTPPayloadJSONArray, contentType: "application/json"},
+		{name: "gzip ndjson", format: HTTPPayloadNDJSON, gzip: true, contentType: "application/x-ndjson"},
+		{name: "gzip json array", format: HTTPPayloadJSONArray, gzip: true, contentType: "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := newHTTPCollector(t)
+			s, _ := newTestLogger(t, InfoLevel)
+			s.AddHTTPLogger("http", HTTPOptions{
+				URL:           collector.server.URL,
+				Format:        tt.format,
+				Gzip:          tt.gzip,
+				FlushInterval: time.Hour,
+			}, InfoLevel)
+			s.Info("first", String("k", "v"))
+			s.Warn("second")
+			s.Sync()
+
+			requests, bodies := collector.received()
+			if len(requests) != 1 {
+				t.Fatalf("%d requests, want 1", len(requests))
+			}
+			r := requests[0]
+			if r.Method != http.MethodPost || r.Header.Get("Content-Type") != tt.contentType {
+				t.Errorf("%s with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+			}
+			if gzipped := r.Header.Get("Content-Encoding") == "gzip"; gzipped != tt.gzip {
+				t.Errorf("Content-Encoding = %q", r.Header.Get("Content-Encoding"))
+			}
+			entries := collector.entries(t, r, bodies[0])
+			if len(entries) != 2 || entries[0]["msg"] != "first" || entries[0]["k"] != "v" || entries[1]["level"] != "warn" {
+				t.Fatalf("entries = %v", entries)
+			}
+		})
+	}
+}
+
+func TestHTTPHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		opts HTTPOptions
+		want string
+	}{
+		{name: "bearer", opts: HTTPOptions{BearerToken: "token", Username: "ignored"}, want: "Bearer token"},
+		{name: "basic", opts: HTTPOptions{Username: "user", Password: "pass"}, want: "Basic dXNlcjpwYXNz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := newHTTPCollector(t)
+			s, _ := newTestLogger(t, InfoLevel)
+			tt.opts.URL = collector.server.URL
+			tt.opts.Method = http.MethodPut
+			tt.opts.Headers = map[string]string{"X-Api-Key": "key"}
+			tt.opts.FlushInterval = time.Hour
+			s.AddHTTPLogger("http", tt.opts, InfoLevel)
+			s.Info("entry")
+			s.Sync()
+
+			requests, _ := collector.received()
+			if len(requests) != 1 {
+				t.Fatalf("%d requests, want 1", len(requests))
+			}
+			r := requests[0]
+			if r.Method != http.MethodPut || r.Header.Get("X-Api-Key") != "key" || r.Header.Get("Authorization") != tt.want {
+				t.Fatalf("%s with headers %v", r.Method, r.Header)
+			}
+		})
+	}
+}
+
+func TestHTTPRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		want     int
+		failed   bool
+	}{
+		{name: "server error", statuses: []int{500, 503}, want: 3},
+		{name: "too many requests", statuses: []int{429}, want: 2},
+		{name: "retries exhausted", statuses: []int{500, 500, 500, 500}, want: 1 + defaultBatchMaxRetries, failed: true},
+		{name: "client error", statuses: []int{400}, want: 1, failed: true},
+		{name: "not found", statuses: []int{404}, want: 1, failed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := captureStdout(t)
+			collector := newHTTPCollector(t, tt.statuses...)
+			s, _ := newTestLogger(t, InfoLevel)
+			s.SetJsonStdoutLogging(true)
+			s.AddHTTPLogger("http", HTTPOptions{
+				URL:           collector.server.URL,
+				FlushInterval: time.Hour,
+				MinBackoff:    time.Millisecond,
+				MaxBackoff:    time.Millisecond,
+			}, InfoLevel)
+			s.Info("entry")
+			s.Sync()
+
+			if requests, _ := collector.received(); len(requests) != tt.want {
+				t.Fatalf("%d requests, want %d", len(requests), tt.want)
+			}
+			if reported := strings.Contains(stdout(), "http logger http failed"); reported != tt.failed {
+				t.Fatalf("failure reported %v, want %v: %s", reported, tt.failed, stdout())
+			}
+		})
+	}
+}
+
+func TestHTTPSpoolsWhileCollectorIsDown(t *testing.T) {
+	stdout := captureStdout(t)
+	collector := newHTTPCollector(t, 503)
+	s, _ := newTestLogger(t, InfoLevel)
+	s.SetJsonStdoutLogging(true)
+	s.AddHTTPLogger("http", HTTPOptions{
+		URL:           collector.server.URL,
+		FlushInterval: time.Hour,
+		MaxRetries:    -1,
+	}, InfoLevel, WithSpool(SpoolOptions{Dir: t.TempDir(), ReplayInterval: 10 * time.Millisecond}))
+	s.Info("spooled")
+	s.Sync()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		requests, bodies := collector.received()
+		if len(requests) == 2 {
+			entries := collector.entries(t, requests[1], bodies[1])
+			if len(entries) != 1 || entries[0]["msg"] != "spooled" {
+				t.Fatalf("replayed entries = %v", entries)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests, want the failed send and the replay", len(requests))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !strings.Contains(stdout(), "spooling entries to disk") {
+		t.Fatalf("spool hand-off not reported: %s", stdout())
+	}
+}
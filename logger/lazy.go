@@ -0,0 +1,71 @@
+package logger
+
+import (
+	"fmt"
+	"go.uber.org/zap/zapcore"
+)
+
+// Lazy constructs a field whose value is computed by fn only if the entry is written by at least one enabled
+// LogInstance. fn is called once per written entry, on the logging goroutine, and the result is shared by every
+// instance. A Lazy field added to a context with WithFields is computed again for every entry. One given to
+// Logger.With is computed once when With is called, like the other With fields it is encoded only once. The key of
+// the returned field is replaced with key. If fn panics the field holds an error instead.
+// example: log.Debug("request", logger.Lazy("body", func() logger.Field { return logger.String("", dump(req)) }))
+//
+//goland:noinspection GoUnusedExportedFunction
+func Lazy(key string, fn func() Field) Field {
+	return Field{
+		Key:       key,
+		Type:      zapcore.InlineMarshalerType,
+		Interface: &lazyField{key: key, fn: fn},
+	}
+}
+
+// LazyObject is Lazy for an object built by fn.
+//
+//goland:noinspection GoUnusedExportedFunction
+func LazyObject(key string, fn func() zapcore.ObjectMarshaler) Field {
+	return Lazy(key, func() Field {
+		return Object(key, fn())
+	})
+}
+
+// lazyField is resolved by fieldsToZapFields once the entry passed the level check. The value is not cached on the
+// field, a field held by a context is resolved for each entry. It also marshals itself for the fields encoded without
+// fieldsToZapFields.
+type lazyField struct {
+	key string
+	fn  func() Field
+}
+
+func (l *lazyField) resolve() Field {
+	return evaluateLazy(l.key, l.fn)
+}
+
+func (l *lazyField) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	zapcore.Field(l.resolve()).AddTo(enc)
+	return nil
+}
+
+func evaluateLazy(key string, fn func() Field) (field Field) {
+	defer func() {
+		if r := recover(); r != nil {
+			field = NamedError(key, fmt.Errorf("lazy field %s panicked: %v", key, r))
+		}
+	}()
+	field = fn()
+	if field.Type != zapcore.InlineMarshalerType && field.Type != zapcore.SkipType {
+		field.Key = key
+	}
+	return
+}
+
+// resolveLazy returns the value of field if it is a Lazy field.
+func resolveLazy(field Field) Field {
+	if field.Type == zapcore.InlineMarshalerType {
+		if lazy, isLazy := field.Interface.(*lazyField); isLazy {
+			return lazy.resolve()
+		}
+	}
+	return field
+}
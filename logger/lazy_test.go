@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"context"
+	"go.uber.org/zap/zapcore"
+	"testing"
+)
+
+// lazyCounter is a Lazy field value that counts its evaluations.
+type lazyCounter struct {
+	calls int
+}
+
+func (c *lazyCounter) field() Field {
+	return Lazy("calls", func() Field {
+		c.calls++
+		return Int("", c.calls)
+	})
+}
+
+func TestLazyEvaluatedPerEntry(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	s.AddLogger("second", new(syncBuffer), InfoLevel)
+	counter := new(lazyCounter)
+	ctx := WithFields(WithLogger(context.Background(), s), counter.field())
+
+	OfMust(ctx).Debug("disabled")
+	if counter.calls != 0 {
+		t.Fatalf("Lazy evaluated for a disabled entry")
+	}
+	OfMust(ctx).Info("first")
+	OfMust(ctx).Info("second")
+
+	entries := buf.entries(t)
+	if len(entries) != 2 || entries[0]["calls"] != float64(1) || entries[1]["calls"] != float64(2) {
+		t.Fatalf("context Lazy field not evaluated once per entry: %q", buf.lines())
+	}
+	if counter.calls != 2 {
+		t.Fatalf("evaluated %d times for two entries written to two instances", counter.calls)
+	}
+}
+
+func TestLazyInWithEvaluatedOnce(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	counter := new(lazyCounter)
+	child := s.With(counter.field())
+	if counter.calls != 1 {
+		t.Fatalf("With evaluated Lazy %d times, want 1", counter.calls)
+	}
+	child.Info("first")
+	child.Info("second")
+
+	for _, entry := range buf.entries(t) {
+		if entry["calls"] != float64(1) {
+			t.Fatalf("With Lazy field re-evaluated: %q", buf.lines())
+		}
+	}
+	if counter.calls != 1 {
+		t.Fatalf("With Lazy field evaluated %d times, want 1", counter.calls)
+	}
+}
+
+func TestLazyPanicRecovered(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	s.Info("entry", Lazy("body", func() Field {
+		panic("boom")
+	}), String("after", "kept"))
+
+	entry := lastEntry(t, buf)
+	if entry["body"] != "lazy field body panicked: boom" || entry["after"] != "kept" {
+		t.Fatalf("unexpected entry %v", entry)
+	}
+}
+
+func TestLazyObject(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	var calls int
+	field := LazyObject("request", func() zapcore.ObjectMarshaler {
+		calls++
+		return zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddString("path", "/status")
+			enc.AddInt("call", calls)
+			return nil
+		})
+	})
+	s.Debug("disabled", field)
+	if calls != 0 {
+		t.Fatal("LazyObject evaluated for a disabled entry")
+	}
+	s.Info("first", field)
+	s.Info("second", field)
+
+	entries := buf.entries(t)
+	for i, entry := range entries {
+		request, _ := entry["request"].(map[string]interface{})
+		if request["path"] != "/status" || request["call"] != float64(i+1) {
+			t.Fatalf("entry %d = %v", i, entry)
+		}
+	}
+	if len(entries) != 2 || calls != 2 {
+		t.Fatalf("%d entries with %d evaluations, want 2 and 2", len(entries), calls)
+	}
+}
@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"fmt"
+	"go.uber.org/zap/zapcore"
+	"strconv"
+	"time"
+)
+
+// LevelRule lowers the effective level of entries with a field Key with Value, e.g. to log at Debug for one tenant.
+// The field can be passed at the call site, added with Logger.With or added to the context with WithFields, and is
+// resolved the way duplicate keys are: a call-site field overrides a With field which overrides a context field.
+// Matching entries are written to every enabled LogInstance regardless of its level and marked with a "forced" field.
+type LevelRule struct {
+	// Key is the field key to match.
+	Key string
+	// Value is compared with the string form of the field value.
+	Value string
+	// Level is the effective level of matching entries.
+	Level Level
+	// Expires removes the rule at this time. The zero value never expires.
+	Expires time.Time
+}
+
+// levelRules is replaced, never modified, so it can be read without locking.
+type levelRules struct {
+	rules map[string]LevelRule
+	// minLevel is the lowest level of the rules, entries below it cannot match any rule
+	minLevel zapcore.Level
+}
+
+// AddLevelRule adds or replaces the rule with name. An expired rule is removed.
+// example: logger.Instance().AddLevelRule("debug-acme", logger.LevelRule{Key: "tenant_id", Value: "acme", Level: logger.DebugLevel, Expires: time.Now().Add(time.Hour)})
+func (s *Logger) AddLevelRule(name string, rule LevelRule) {
+	s.cfgMutex.Lock()
+	defer s.cfgMutex.Unlock()
+	s.updateLevelRules(func(rules map[string]LevelRule) {
+		rules[name] = rule
+	})
+	if !rule.Expires.IsZero() {
+		time.AfterFunc(time.Until(rule.Expires), func() {
+			s.removeExpiredLevelRule(name, rule)
+		})
+	}
+}
+
+// RemoveLevelRule removes the rule with name.
+func (s *Logger) RemoveLevelRule(name string) {
+	s.cfgMutex.Lock()
+	defer s.cfgMutex.Unlock()
+	s.updateLevelRules(func(rules map[string]LevelRule) {
+		delete(rules, name)
+	})
+}
+
+// LevelRules returns the rules that have not expired by name.
+func (s *Logger) LevelRules() (rules map[string]LevelRule) {
+	rules = make(map[string]LevelRule)
+	if current := s.levelRules.Load(); current != nil {
+		for name, rule := range current.rules {
+			rules[name] = rule
+		}
+	}
+	return
+}
+
+// removeExpiredLevelRule removes the rule with name unless it was replaced since it was added.
+func (s *Logger) removeExpiredLevelRule(name string, rule LevelRule) {
+	s.cfgMutex.Lock()
+	defer s.cfgMutex.Unlock()
+	s.updateLevelRules(func(rules map[string]LevelRule) {
+		if rules[name] == rule {
+			delete(rules, name)
+		}
+	})
+}
+
+// updateLevelRules replaces the rules with a copy changed by update. Callers must hold cfgMutex.
+func (s *Logger) updateLevelRules(update func(rules map[string]LevelRule)) {
+	rules := make(map[string]LevelRule)
+	if current := s.levelRules.Load(); current != nil {
+		for name, rule := range current.rules {
+			rules[name] = rule
+		}
+	}
+	update(rules)
+	now := time.Now()
+	for name, rule := range rules {
+		if !rule.Expires.IsZero() && !rule.Expires.After(now) {
+			delete(rules, name)
+		}
+	}
+	if len(rules) == 0 {
+		s.levelRules.Store(nil)
+		return
+	}
+	compiled := &levelRules{rules: rules, minLevel: zapcore.InvalidLevel}
+	for _, rule := range rules {
+		if zapcore.Level(rule.Level) < compiled.minLevel {
+			compiled.minLevel = zapcore.Level(rule.Level)
+		}
+	}
+	s.levelRules.Store(compiled)
+}
+
+// ruleLevel returns the lowest level of the rules matching the call-site fields, the With fields of the Logger and
+// the context fields of an entry at entryLevel. Rules above entryLevel cannot force the entry and are not matched, so
+// the fields are not scanned at all for entries below every rule.
+func (s *Logger) ruleLevel(entryLevel zapcore.Level, fields []Field, contextFields []Field) (level zapcore.Level, forced bool) {
+	rules := s.levelRules.Load()
+	if rules == nil || entryLevel < rules.minLevel {
+		return
+	}
+	level = zapcore.InvalidLevel
+	for _, rule := range rules.rules {
+		if zapcore.Level(rule.Level) >= level || zapcore.Level(rule.Level) > entryLevel {
+			continue
+		}
+		field, found := lastField(fields, rule.Key)
+		if !found {
+			field, found = lastField(s.withFields, rule.Key)
+		}
+		if !found {
+			field, found = lastField(contextFields, rule.Key)
+		}
+		if !found {
+			continue
+		}
+		if value, ok := fieldValueString(field); ok && value == rule.Value {
+			level = zapcore.Level(rule.Level)
+			forced = true
+		}
+	}
+	return
+}
+
+// lastField returns the last field with key, the one that is written when fields has duplicates.
+func lastField(fields []Field, key string) (field Field, found bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Key == key {
+			return fields[i], true
+		}
+	}
+	return
+}
+
+// fieldValueString returns the string form of the value of scalar fields.
+func fieldValueString(field Field) (string, bool) {
+	switch field.Type {
+	case zapcore.StringType:
+		return field.String, true
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+		return strconv.FormatInt(field.Integer, 10), true
+	case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type, zapcore.UintptrType:
+		return strconv.FormatUint(uint64(field.Integer), 10), true
+	case zapcore.BoolType:
+		return strconv.FormatBool(field.Integer == 1), true
+	case zapcore.StringerType:
+		if stringer, ok := field.Interface.(fmt.Stringer); ok {
+			return stringer.String(), true
+		}
+	}
+	return "", false
+}
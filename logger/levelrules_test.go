@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestLevelRuleMatchesEntryFields(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	s.AddLevelRule("debug-u1", LevelRule{Key: "user_id", Value: "u1", Level: DebugLevel})
+	ctx := WithLogger(context.Background(), s)
+
+	s.Debug("call-site", String("user_id", "u1"))
+	s.With(String("user_id", "u1")).Debug("with")
+	OfMust(WithFields(ctx, String("user_id", "u1"))).Debug("context")
+	OfMust(ctx).Debug("context call-site", String("user_id", "u1"))
+	// the resolved value decides, a call-site field overrides the context field
+	OfMust(WithFields(ctx, String("user_id", "u1"))).Debug("overridden", String("user_id", "u2"))
+	s.With(String("user_id", "u1")).Debug("overridden with", String("user_id", "u2"))
+	s.Debug("other user", String("user_id", "u2"))
+
+	var messages []string
+	for _, entry := range buf.entries(t) {
+		if entry["forced"] != true {
+			t.Errorf("entry %q not marked forced", entry["msg"])
+		}
+		messages = append(messages, entry["msg"].(string))
+	}
+	want := []string{"call-site", "with", "context", "context call-site"}
+	if !reflect.DeepEqual(messages, want) {
+		t.Fatalf("messages = %q, want %q", messages, want)
+	}
+}
+
+func TestLevelRuleOnlyForcesEntriesAtItsLevel(t *testing.T) {
+	s, buf := newTestLogger(t, WarnLevel)
+	s.AddLevelRule("info-u1", LevelRule{Key: "user_id", Value: "u1", Level: InfoLevel})
+	s.AddLevelRule("debug-u2", LevelRule{Key: "user_id", Value: "u2", Level: DebugLevel})
+
+	s.Debug("below rule", String("user_id", "u1"))
+	s.Info("at rule", String("user_id", "u1"))
+	s.Debug("other rule", String("user_id", "u2"))
+	s.RemoveLevelRule("debug-u2")
+	s.Debug("removed rule", String("user_id", "u2"))
+
+	var messages []string
+	for _, entry := range buf.entries(t) {
+		messages = append(messages, entry["msg"].(string))
+	}
+	if want := []string{"at rule", "other rule"}; !reflect.DeepEqual(messages, want) {
+		t.Fatalf("messages = %q, want %q", messages, want)
+	}
+}
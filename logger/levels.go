@@ -1,13 +1,22 @@
 package logger
 
+import (
+	"fmt"
+	"go.uber.org/zap/zapcore"
+	"strings"
+)
+
 // A Level is a logging priority. Higher levels are more important.
 type Level int8
 
 //goland:noinspection GoUnusedConst
 const (
+	// TraceLevel logs are wire-level detail, more voluminous than Debug. They
+	// are enabled separately from Debug.
+	TraceLevel Level = iota - 2
 	// DebugLevel logs are typically voluminous, and are usually disabled in
 	// production.
-	DebugLevel Level = iota - 1
+	DebugLevel
 	// InfoLevel is the default logging priority.
 	InfoLevel
 	// WarnLevel logs are more important than Info, but don't need individual
@@ -24,6 +33,89 @@ const (
 	// FatalLevel logs a message, then calls os.Exit(1).
 	FatalLevel
 
-	//_minLevel = DebugLevel
+	//_minLevel = TraceLevel
 	//_maxLevel = FatalLevel
 )
+
+// String returns the lowercase name of the level.
+func (l Level) String() string {
+	return levelName(zapcore.Level(l))
+}
+
+// ParseLevel parses a level name as returned by String. It is case-insensitive and an empty string is InfoLevel.
+func ParseLevel(text string) (Level, error) {
+	switch strings.ToLower(text) {
+	case "trace":
+		return TraceLevel, nil
+	case "debug":
+		return DebugLevel, nil
+	case "info", "":
+		return InfoLevel, nil
+	case "warn", "warning":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	case "dpanic":
+		return DPanicLevel, nil
+	case "panic":
+		return PanicLevel, nil
+	case "fatal":
+		return FatalLevel, nil
+	default:
+		return InfoLevel, fmt.Errorf("unrecognized level: %q", text)
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (l *Level) UnmarshalText(text []byte) (err error) {
+	*l, err = ParseLevel(string(text))
+	return
+}
+
+// Set implements flag.Value.
+func (l *Level) Set(text string) error {
+	return l.UnmarshalText([]byte(text))
+}
+
+// Get implements flag.Getter.
+func (l *Level) Get() interface{} {
+	return *l
+}
+
+// zap only knows the levels from Debug up, the encoders below add a name for TraceLevel.
+
+// levelName returns the lowercase name of level.
+func levelName(level zapcore.Level) string {
+	if level == zapcore.Level(TraceLevel) {
+		return "trace"
+	}
+	return level.String()
+}
+
+// encodedLevelName returns the name of level written by the JSON encoders and network sinks: the lowercase zap name,
+// and "TRACE" for TraceLevel so trace output stands out from debug.
+func encodedLevelName(level zapcore.Level) string {
+	if level == zapcore.Level(TraceLevel) {
+		return "TRACE"
+	}
+	return level.String()
+}
+
+// jsonLevelEncoder is zapcore.LowercaseLevelEncoder with TraceLevel encoded as "TRACE".
+func jsonLevelEncoder(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(encodedLevelName(level))
+}
+
+// capitalColorLevelEncoder is zapcore.CapitalColorLevelEncoder with TraceLevel encoded as a cyan "TRACE".
+func capitalColorLevelEncoder(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	if level == zapcore.Level(TraceLevel) {
+		enc.AppendString("\x1b[36mTRACE\x1b[0m")
+		return
+	}
+	zapcore.CapitalColorLevelEncoder(level, enc)
+}
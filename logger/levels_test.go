@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"encoding/json"
+	"flag"
+	"go.uber.org/zap/zapcore"
+	"io"
+	"testing"
+)
+
+func TestTraceLevelEncodedAsTRACE(t *testing.T) {
+	s, buf := newTestLogger(t, TraceLevel)
+	s.Trace("trace entry")
+	s.Debug("debug entry")
+
+	entries := buf.entries(t)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0]["level"] != "TRACE" || entries[1]["level"] != "debug" {
+		t.Fatalf("levels = %v, %v, want TRACE, debug", entries[0]["level"], entries[1]["level"])
+	}
+}
+
+func TestTraceLevelEnabledSeparately(t *testing.T) {
+	s, buf := newTestLogger(t, DebugLevel)
+	if s.IsLevelEnabled(TraceLevel) {
+		t.Fatal("TraceLevel enabled at DebugLevel")
+	}
+	s.Trace("trace entry")
+	if lines := buf.lines(); len(lines) != 0 {
+		t.Fatalf("trace entry written at DebugLevel: %q", lines)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Level
+		wantErr bool
+	}{
+		{text: "trace", want: TraceLevel},
+		{text: "TRACE", want: TraceLevel},
+		{text: "debug", want: DebugLevel},
+		{text: "Debug", want: DebugLevel},
+		{text: "info", want: InfoLevel},
+		{text: "", want: InfoLevel},
+		{text: "warn", want: WarnLevel},
+		{text: "WARNING", want: WarnLevel},
+		{text: "error", want: ErrorLevel},
+		{text: "dpanic", want: DPanicLevel},
+		{text: "DPanic", want: DPanicLevel},
+		{text: "panic", want: PanicLevel},
+		{text: "fatal", want: FatalLevel},
+		{text: "FATAL", want: FatalLevel},
+		{text: "verbose", wantErr: true},
+		{text: "info ", wantErr: true},
+		{text: "1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseLevel(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLevel(%q) error = %v, wantErr %v", tt.text, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Fatalf("ParseLevel(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLevelTextRoundTrip(t *testing.T) {
+	for _, level := range []Level{TraceLevel, DebugLevel, InfoLevel, WarnLevel, ErrorLevel, DPanicLevel, PanicLevel, FatalLevel} {
+		t.Run(level.String(), func(t *testing.T) {
+			encoded, err := json.Marshal(struct{ Level Level }{level})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded struct{ Level Level }
+			if err = json.Unmarshal(encoded, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Level != level {
+				t.Fatalf("%s decoded as %v", encoded, decoded.Level)
+			}
+		})
+	}
+	var level Level
+	if err := level.UnmarshalText([]byte("verbose")); err == nil {
+		t.Fatal("UnmarshalText accepted an invalid level")
+	}
+}
+
+func TestRegisterFlags(t *testing.T) {
+	s := NewLogger()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	s.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-log-level", "TRACE", "-log-console", "-log-file-level=warn"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.Lookup("log-level").Value.String(); got != "trace" {
+		t.Fatalf("log-level = %q, want trace", got)
+	}
+	s.StartTask()
+	t.Cleanup(s.StopTask)
+
+	cfg := s.config()
+	if !cfg.enabled[debugConsoleKey] || cfg.enabled[jsonStdoutKey] || cfg.enabled[fileKey] {
+		t.Fatalf("enabled = %v", cfg.enabled)
+	}
+	if got := cfg.instances[debugConsoleKey].level.Level(); got != zapcore.Level(TraceLevel) {
+		t.Fatalf("console level = %v, want trace", got)
+	}
+	if got := cfg.instances[fileKey].level.Level(); got != zapcore.WarnLevel {
+		t.Fatalf("file level = %v, want warn", got)
+	}
+
+	invalid := flag.NewFlagSet("test", flag.ContinueOnError)
+	invalid.SetOutput(io.Discard)
+	NewLogger().RegisterFlags(invalid)
+	if err := invalid.Parse([]string{"-log-level", "verbose"}); err == nil {
+		t.Fatal("Parse accepted an invalid level")
+	}
+}
@@ -3,165 +3,172 @@ package logger
 import (
 	"context"
 	"fmt"
-	"os"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
-func (s *Logger) Trace(msg string, fields ...Field) {
-	cfg := s.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Debug(msg, fieldsToZapFields(fields...)...)
+// log writes an entry at level to every enabled LogInstance. It must be called directly by the exported level
+// methods of Logger and ContextLogger, the caller skip set in loggerConfig.compile depends on it.
+func (s *Logger) log(level zapcore.Level, msg string, fields []Field) {
+	logger := s.dispatchLogger(level, fields)
+	if logger == nil {
+		if recorder := s.config().flightRecorder; recorder != nil {
+			recorder.record(s, level, msg, fields, nil)
 		}
+		return
+	}
+	if ce := logger.Check(level, msg); ce != nil {
+		ce.Write(fieldsToZapFields(s.resolveFields(msg, fields, nil)...)...)
 	}
 }
 
-func (s *Logger) Debug(msg string, fields ...Field) {
-	cfg := s.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Debug(msg, fieldsToZapFields(fields...)...)
-		}
+// check returns a zapcore.CheckedEntry if an entry at level would be written. Like log it must be called directly
+// by an exported method.
+func (s *Logger) check(level zapcore.Level, msg string) *zapcore.CheckedEntry {
+	logger := s.dispatchLogger(level, nil)
+	if logger == nil {
+		return nil
 	}
+	return logger.Check(level, msg)
 }
 
-func (s *Logger) Info(msg string, fields ...Field) {
-	cfg := s.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Info(msg, fieldsToZapFields(fields...)...)
-		}
+// dispatchLogger returns the zap logger to check an entry at level with the call-site fields, or nil if no enabled
+// LogInstance would accept it. A level rule matching the entry fields selects the logger that ignores instance levels.
+func (s *Logger) dispatchLogger(level zapcore.Level, fields []Field) *zap.Logger {
+	if ruleLevel, forced := s.ruleLevel(level, fields, nil); forced && level >= ruleLevel {
+		return s.loggersOf(s.config()).forced
 	}
+	if level < zapcore.DPanicLevel && !s.enabled(level) {
+		return nil
+	}
+	return s.loggersOf(s.config()).logger
+}
+
+// CheckedEntry is an entry that has passed the level check of at least one enabled LogInstance. Use it to avoid
+// building expensive fields for entries that would be discarded.
+//
+//	if ce := log.Check(logger.DebugLevel, "request body"); ce != nil {
+//		ce.Write(logger.String("body", dump(req)))
+//	}
+type CheckedEntry struct {
+	ce            *zapcore.CheckedEntry
+	logger        *Logger
+	contextFields []Field
+	// set by ContextLogger.Check in a request buffer scope
+	buffer *requestBuffer
+}
+
+// Write writes the entry with fields. A CheckedEntry must not be written more than once.
+func (c *CheckedEntry) Write(fields ...Field) {
+	if c == nil {
+		return
+	}
+	fields = c.logger.resolveFields(c.ce.Message, fields, c.contextFields)
+	if c.buffer != nil {
+		c.buffer.write(c.ce, fieldsToZapFields(fields...))
+		return
+	}
+	c.ce.Write(fieldsToZapFields(fields...)...)
+}
+
+// Check returns a CheckedEntry if an entry at level would be written by any enabled LogInstance, otherwise nil.
+func (s *Logger) Check(level Level, msg string) *CheckedEntry {
+	ce := s.check(zapcore.Level(level), msg)
+	if ce == nil {
+		return nil
+	}
+	return &CheckedEntry{
+		ce:     ce,
+		logger: s,
+	}
+}
+
+func (s *Logger) Trace(msg string, fields ...Field) {
+	s.log(zapcore.Level(TraceLevel), msg, fields)
+}
+
+func (s *Logger) Debug(msg string, fields ...Field) {
+	s.log(zapcore.DebugLevel, msg, fields)
+}
+
+func (s *Logger) Info(msg string, fields ...Field) {
+	s.log(zapcore.InfoLevel, msg, fields)
 }
 
 func (s *Logger) InfoIgnoreCancel(ctx context.Context, msg string, fields ...Field) {
 	if ctx.Err() != nil {
 		return
 	}
-	if fieldsContainContextCancelled(fields...) {
+	if s.fieldsContainContextCancelled(fields...) {
 		return
 	}
-	s.Info(msg, fields...)
+	s.log(zapcore.InfoLevel, msg, fields)
 }
 
 func (s *Logger) Warn(msg string, fields ...Field) {
-	cfg := s.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Warn(msg, fieldsToZapFields(fields...)...)
-		}
-	}
+	s.log(zapcore.WarnLevel, msg, fields)
 }
 
 func (s *Logger) WarnIgnoreCancel(ctx context.Context, msg string, fields ...Field) {
 	if ctx.Err() != nil {
 		return
 	}
-	if fieldsContainContextCancelled(fields...) {
+	if s.fieldsContainContextCancelled(fields...) {
 		return
 	}
-	s.Warn(msg, fields...)
+	s.log(zapcore.WarnLevel, msg, fields)
 }
 
 func (s *Logger) Error(msg string, fields ...Field) {
-	cfg := s.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Error(msg, fieldsToZapFields(fields...)...)
-		}
-	}
+	s.log(zapcore.ErrorLevel, msg, fields)
 }
 
 func (s *Logger) ErrorIgnoreCancel(ctx context.Context, msg string, fields ...Field) {
 	if ctx.Err() != nil {
 		return
 	}
-	if fieldsContainContextCancelled(fields...) {
+	if s.fieldsContainContextCancelled(fields...) {
 		return
 	}
-	s.Error(msg, fields...)
+	s.log(zapcore.ErrorLevel, msg, fields)
 }
 
 func (s *Logger) Panic(msg string, fields ...Field) {
-	cfg := s.config()
-	var foundLogger bool
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			foundLogger = true
-			logInstance.logger.Panic(msg, fieldsToZapFields(fields...)...)
-		}
-	}
-	if !foundLogger {
-		panic(msg)
-	}
+	s.log(zapcore.PanicLevel, msg, fields)
 }
 
 func (s *Logger) DPanic(msg string, fields ...Field) {
-	cfg := s.config()
-	var foundLogger bool
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			foundLogger = true
-			logInstance.logger.DPanic(msg, fieldsToZapFields(fields...)...)
-		}
-	}
-	if !foundLogger {
-		panic(msg)
+	if cfg := s.config(); !cfg.anyEnabled {
+		cfg.terminate(msg, false)
 	}
+	s.log(zapcore.DPanicLevel, msg, fields)
 }
 
 func (s *Logger) Fatal(msg string, fields ...Field) {
-	cfg := s.config()
-	var foundLogger bool
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			foundLogger = true
-			logInstance.logger.Fatal(msg, fieldsToZapFields(fields...)...)
-		}
-	}
-	if !foundLogger {
+	if !s.config().anyEnabled {
 		fmt.Println(msg)
-		os.Exit(1)
 	}
+	s.log(zapcore.FatalLevel, msg, fields)
 }
 
 // Deprecated: use structured logging instead.
 func (s *Logger) TraceUnstruct(args ...interface{}) {
-	cfg := s.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Sugar().Debug(args...)
-		}
-	}
+	s.sugar().Log(zapcore.Level(TraceLevel), args...)
 }
 
 // Deprecated: use structured logging instead.
 func (s *Logger) DebugUnstruct(args ...interface{}) {
-	cfg := s.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Sugar().Debug(args...)
-		}
-	}
+	s.sugar().Debug(args...)
 }
 
 // Deprecated: use structured logging instead.
 func (s *Logger) InfoUnstruct(args ...interface{}) {
-	cfg := s.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Sugar().Info(args...)
-		}
-	}
+	s.sugar().Info(args...)
 }
 
 // Deprecated: use structured logging instead.
 func (s *Logger) WarnUnstruct(args ...interface{}) {
-	cfg := s.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Sugar().Warn(args...)
-		}
-	}
+	s.sugar().Warn(args...)
 }
 
 // Deprecated: use structured logging instead.
@@ -169,17 +176,12 @@ func (s *Logger) WarnIgnoreCancelUnstruct(ctx context.Context, args ...interface
 	if ctx.Err() != nil {
 		return
 	}
-	s.WarnUnstruct(args)
+	s.sugar().Warn(args...)
 }
 
 // Deprecated: use structured logging instead.
 func (s *Logger) ErrorUnstruct(args ...interface{}) {
-	cfg := s.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Sugar().Error(args...)
-		}
-	}
+	s.sugar().Error(args...)
 }
 
 // Deprecated: use structured logging instead.
@@ -187,105 +189,50 @@ func (s *Logger) ErrorIgnoreCancelUnstruct(ctx context.Context, args ...interfac
 	if ctx.Err() != nil {
 		return
 	}
-	s.ErrorUnstruct(args)
+	s.sugar().Error(args...)
 }
 
 // Deprecated: use structured logging instead.
 func (s *Logger) PanicUnstruct(args ...interface{}) {
-	cfg := s.config()
-	var foundLogger bool
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			foundLogger = true
-			logInstance.logger.Sugar().Panic(args...)
-		}
-	}
-	if !foundLogger {
-		if len(args) >= 1 {
-			if str, ok := args[0].(string); ok {
-				panic(str)
-			}
-		}
-	}
+	s.sugar().Panic(args...)
 }
 
 // Deprecated: use structured logging instead.
 func (s *Logger) DPanicUnstruct(args ...interface{}) {
 	cfg := s.config()
-	var foundLogger bool
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			foundLogger = true
-			logInstance.logger.Sugar().DPanic(args...)
-		}
-	}
-	if !foundLogger {
-		if len(args) >= 1 {
-			if str, ok := args[0].(string); ok {
-				panic(str)
-			}
-		}
+	if !cfg.anyEnabled {
+		cfg.terminate(fmt.Sprint(args...), false)
 	}
+	s.sugar().DPanic(args...)
 }
 
 // Deprecated: use structured logging instead.
 func (s *Logger) FatalUnstruct(args ...interface{}) {
 	cfg := s.config()
-	var foundLogger bool
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			foundLogger = true
-			logInstance.logger.Sugar().Fatal(args...)
-		}
-	}
-	if !foundLogger {
-		if len(args) >= 1 {
-			if str, ok := args[0].(string); ok {
-				fmt.Println(str)
-			}
-		}
-		os.Exit(1)
+	if !cfg.anyEnabled {
+		fmt.Println(fmt.Sprint(args...))
 	}
+	s.sugar().Fatal(args...)
 }
 
 // Deprecated: use structured logging instead.
 func (s *Logger) TracefUnstruct(format string, args ...interface{}) {
-	cfg := s.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Sugar().Debugf(format, args...)
-		}
-	}
+	s.sugar().Logf(zapcore.Level(TraceLevel), format, args...)
 }
 
 // Deprecated: use structured logging instead.
 func (s *Logger) DebugfUnstruct(format string, args ...interface{}) {
-	cfg := s.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Sugar().Debugf(format, args...)
-		}
-	}
+	s.sugar().Debugf(format, args...)
 }
 
 // Deprecated: use structured logging instead.
 func (s *Logger) InfofUnstruct(format string, args ...interface{}) {
-	cfg := s.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Sugar().Infof(format, args...)
-		}
-	}
+	s.sugar().Infof(format, args...)
 }
 
 // Deprecated: use structured logging instead.
 func (s *Logger) WarnfUnstruct(format string, args ...interface{}) {
-	cfg := s.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Sugar().Warnf(format, args...)
-		}
-	}
+	s.sugar().Warnf(format, args...)
 }
 
 // Deprecated: use structured logging instead.
@@ -293,17 +240,12 @@ func (s *Logger) WarnfIgnoreCancelUnstruct(ctx context.Context, format string, a
 	if ctx.Err() != nil {
 		return
 	}
-	s.WarnfUnstruct(format, args)
+	s.sugar().Warnf(format, args...)
 }
 
 // Deprecated: use structured logging instead.
 func (s *Logger) ErrorfUnstruct(format string, args ...interface{}) {
-	cfg := s.config()
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			logInstance.logger.Sugar().Errorf(format, args...)
-		}
-	}
+	s.sugar().Errorf(format, args...)
 }
 
 // Deprecated: use structured logging instead.
@@ -311,51 +253,28 @@ func (s *Logger) ErrorfIgnoreCancelUnstruct(ctx context.Context, format string,
 	if ctx.Err() != nil {
 		return
 	}
-	s.ErrorfUnstruct(format, args)
+	s.sugar().Errorf(format, args...)
 }
 
 // Deprecated: use structured logging instead.
 func (s *Logger) PanicfUnstruct(format string, args ...interface{}) {
-	cfg := s.config()
-	var foundLogger bool
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			foundLogger = true
-			logInstance.logger.Sugar().Panicf(format, args...)
-		}
-	}
-	if !foundLogger {
-		panic(fmt.Sprintf(format, args...))
-	}
+	s.sugar().Panicf(format, args...)
 }
 
 // Deprecated: use structured logging instead.
 func (s *Logger) DPanicfUnstruct(format string, args ...interface{}) {
 	cfg := s.config()
-	var foundLogger bool
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			foundLogger = true
-			logInstance.logger.Sugar().DPanicf(format, args...)
-		}
-	}
-	if !foundLogger {
-		panic(fmt.Sprintf(format, args...))
+	if !cfg.anyEnabled {
+		cfg.terminate(fmt.Sprintf(format, args...), false)
 	}
+	s.sugar().DPanicf(format, args...)
 }
 
 // Deprecated: use structured logging instead.
 func (s *Logger) FatalfUnstruct(format string, args ...interface{}) {
 	cfg := s.config()
-	var foundLogger bool
-	for _, logInstance := range cfg.instances {
-		if logInstance.enabled.Load() {
-			foundLogger = true
-			logInstance.logger.Sugar().Fatalf(format, args...)
-		}
-	}
-	if !foundLogger {
+	if !cfg.anyEnabled {
 		fmt.Println(fmt.Sprintf(format, args...))
-		os.Exit(1)
 	}
+	s.sugar().Fatalf(format, args...)
 }
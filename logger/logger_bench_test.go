@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// newBenchLogger starts a Logger with instances JSON LogInstances at Info writing to io.Discard.
+func newBenchLogger(b *testing.B, instances int) *Logger {
+	b.Helper()
+	s := NewLogger()
+	s.StartTask()
+	b.Cleanup(s.StopTask)
+	for i := 0; i < instances; i++ {
+		s.AddLogger(fmt.Sprintf("bench-%d", i), io.Discard, InfoLevel)
+	}
+	return s
+}
+
+var benchInstanceCounts = []int{1, 4}
+
+func BenchmarkInfo(b *testing.B) {
+	for _, instances := range benchInstanceCounts {
+		b.Run(fmt.Sprintf("instances=%d", instances), func(b *testing.B) {
+			s := newBenchLogger(b, instances)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.Info("benchmark", String("key", "value"), Int("count", i))
+			}
+		})
+	}
+}
+
+// BenchmarkInfoPerInstance logs the way entries were dispatched before the tee core, converting the fields and
+// checking the level once per LogInstance, as the baseline for BenchmarkInfo.
+func BenchmarkInfoPerInstance(b *testing.B) {
+	for _, instances := range benchInstanceCounts {
+		b.Run(fmt.Sprintf("instances=%d", instances), func(b *testing.B) {
+			s := newBenchLogger(b, instances)
+			cfg := s.config()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				fields := []Field{String("key", "value"), Int("count", i)}
+				for key, logInstance := range cfg.instances {
+					if cfg.enabled[key] {
+						logInstance.logger.Info("benchmark", fieldsToZapFields(fields...)...)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDebugDisabled(b *testing.B) {
+	s := newBenchLogger(b, 4)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Debug("benchmark", String("key", "value"), Int("count", i))
+	}
+}
+
+func BenchmarkContextLoggerInfo(b *testing.B) {
+	s := newBenchLogger(b, 4)
+	ctx := WithFields(WithLogger(context.Background(), s), String("request", "abc"))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		OfMust(ctx).Info("benchmark", Int("count", i))
+	}
+}
+
+// BenchmarkContextLoggerDebugDisabledWithRules checks that level rules above the entry level do not add a field scan
+// to disabled entries.
+func BenchmarkContextLoggerDebugDisabledWithRules(b *testing.B) {
+	s := newBenchLogger(b, 4)
+	for i := 0; i < 5; i++ {
+		s.AddLevelRule(fmt.Sprintf("rule-%d", i), LevelRule{Key: "tenant_id", Value: fmt.Sprint(i), Level: InfoLevel})
+	}
+	ctx := WithFields(WithLogger(context.Background(), s), String("request", "abc"), String("tenant_id", "other"))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		OfMust(ctx).Debug("benchmark", Int("count", i))
+	}
+}
@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCheckBelowEveryInstanceLevel(t *testing.T) {
+	s, _ := newTestLogger(t, InfoLevel)
+	buf := new(syncBuffer)
+	s.AddLogger("warn", buf, WarnLevel)
+	log := OfMust(WithLogger(context.Background(), s))
+
+	if s.Check(DebugLevel, "debug") != nil || log.Check(DebugLevel, "debug") != nil {
+		t.Fatal("Check(DebugLevel) with every instance at Info or above")
+	}
+	if s.Check(InfoLevel, "info") == nil || log.Check(InfoLevel, "info") == nil {
+		t.Fatal("Check(InfoLevel) with an instance at Info")
+	}
+	s.SetLoggerEnabled("test", false)
+	if s.Check(InfoLevel, "info") != nil {
+		t.Fatal("Check(InfoLevel) with the Info instance disabled")
+	}
+}
+
+func TestCheckedEntryWrite(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	ctx := WithFields(WithLogger(context.Background(), s), String("request", "abc"))
+	if ce := OfMust(ctx).Check(InfoLevel, "checked"); ce != nil {
+		ce.Write(Int("count", 3))
+	}
+
+	entry := lastEntry(t, buf)
+	if entry["msg"] != "checked" || entry["request"] != "abc" || entry["count"] != float64(3) {
+		t.Fatalf("unexpected entry %v", entry)
+	}
+
+	// a nil CheckedEntry from a disabled level can be written
+	var nilEntry *CheckedEntry
+	nilEntry.Write(String("k", "v"))
+	OfMust(ctx).Check(DebugLevel, "disabled").Write()
+	if lines := buf.lines(); len(lines) != 1 {
+		t.Fatalf("entries = %q, want only the checked entry", lines)
+	}
+}
+
+func TestCheckedEntryHeldInRequestBuffer(t *testing.T) {
+	s, buf := newTestLogger(t, DebugLevel)
+	ctx, end := WithRequestBuffer(WithLogger(context.Background(), s))
+	defer end()
+	log := OfMust(ctx)
+	log.Check(DebugLevel, "held").Write()
+	if lines := buf.lines(); len(lines) != 0 {
+		t.Fatalf("checked entry written in a request buffer scope: %q", lines)
+	}
+	log.Error("failed")
+
+	var messages []string
+	for _, entry := range buf.entries(t) {
+		messages = append(messages, entry["msg"].(string))
+	}
+	if want := []string{"held", "failed"}; !reflect.DeepEqual(messages, want) {
+		t.Fatalf("messages = %q, want %q", messages, want)
+	}
+}
@@ -1,8 +1,23 @@
 package logger
 
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"os"
+)
+
 type loggerConfig struct {
 	instances map[string]*LogInstance
+	enabled   map[string]bool // keys of the instances that are enabled
 	options   *Options
+	exitHooks []func()
+	// flightRecorder is set by StartTask WithFlightRecorder. It is not a LogInstance so it does not lower the minimum
+	// enabled level, entries no instance accepts are added to it by Logger.log and ContextLogger.log.
+	flightRecorder *flightRecorderRing
+
+	// compiled from instances by compile
+	dispatch   *dispatchLoggers
+	anyEnabled bool
 }
 
 func (cfg *loggerConfig) clone() (clone *loggerConfig) {
@@ -11,8 +26,163 @@ func (cfg *loggerConfig) clone() (clone *loggerConfig) {
 	for k, v := range cfg.instances {
 		clone.instances[k] = v
 	}
+	clone.enabled = make(map[string]bool, len(cfg.enabled))
+	for k, v := range cfg.enabled {
+		clone.enabled[k] = v
+	}
 	if cfg.options != nil {
 		clone.options = cfg.options.clone()
 	}
+	clone.exitHooks = make([]func(), len(cfg.exitHooks))
+	copy(clone.exitHooks, cfg.exitHooks)
+	clone.flightRecorder = cfg.flightRecorder
 	return
 }
+
+// setInstance adds or replaces the LogInstance at key.
+func (cfg *loggerConfig) setInstance(key string, logInstance *LogInstance, enabled bool) {
+	cfg.instances[key] = logInstance
+	cfg.enabled[key] = enabled
+}
+
+// removeInstance removes the LogInstance at key.
+func (cfg *loggerConfig) removeInstance(key string) {
+	delete(cfg.instances, key)
+	delete(cfg.enabled, key)
+}
+
+// compile builds the single zap.Logger that dispatches to every enabled LogInstance through a tee core, so each log
+// call does one level check, one caller capture and one field conversion regardless of how many instances there are.
+func (cfg *loggerConfig) compile() {
+	var cores, forcedCores []zapcore.Core
+	stackLevel := zapcore.InvalidLevel
+	var development bool
+	for key, logInstance := range cfg.instances {
+		if !cfg.enabled[key] {
+			continue
+		}
+		cores = append(cores, logInstance.core)
+		forcedCores = append(forcedCores, &forcedCore{
+			Core:     logInstance.core,
+			instance: logInstance,
+		})
+		if logInstance.stackLevel < stackLevel {
+			stackLevel = logInstance.stackLevel
+		}
+		development = development || logInstance.development
+	}
+	anyEnabled := len(cores) > 0
+	if cfg.flightRecorder != nil {
+		// first so an entry that dumps the recorder is written after the dump
+		recorder := &flightRecorderCore{ring: cfg.flightRecorder}
+		cores = append([]zapcore.Core{recorder}, cores...)
+		forcedCores = append([]zapcore.Core{recorder}, forcedCores...)
+	}
+
+	// skip Logger.log and the Logger/ContextLogger level method that called it
+	opts := []zap.Option{
+		zap.AddCaller(),
+		zap.AddCallerSkip(2),
+		zap.AddStacktrace(stackLevel),
+		zap.WithPanicHook(terminalHook{cfg: cfg}),
+		zap.WithFatalHook(terminalHook{cfg: cfg, fatal: true}),
+	}
+	if development {
+		opts = append(opts, zap.Development())
+	}
+	cfg.dispatch = &dispatchLoggers{
+		cfg:    cfg,
+		logger: zap.New(zapcore.NewTee(cores...), opts...),
+		forced: zap.New(zapcore.NewTee(forcedCores...), opts...),
+	}
+	// the sugared logger is called directly by the Unstruct methods
+	cfg.dispatch.sugar = cfg.dispatch.logger.WithOptions(zap.AddCallerSkip(-1)).Sugar()
+	cfg.anyEnabled = anyEnabled
+}
+
+// stackFilterCore drops the stack trace captured by the shared dispatch logger from entries below stackLevel.
+type stackFilterCore struct {
+	zapcore.Core
+	stackLevel zapcore.Level
+}
+
+func (c *stackFilterCore) With(fields []zapcore.Field) zapcore.Core {
+	return &stackFilterCore{
+		Core:       c.Core.With(fields),
+		stackLevel: c.stackLevel,
+	}
+}
+
+func (c *stackFilterCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *stackFilterCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level < c.stackLevel {
+		ent.Stack = ""
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// forcedCore writes every entry to a LogInstance regardless of its level. It is used for entries whose level was
+// lowered by a level rule or WithForcedLevel. Entries the instance would not have accepted otherwise are marked with a "forced" field.
+type forcedCore struct {
+	zapcore.Core
+	instance *LogInstance
+}
+
+func (c *forcedCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c *forcedCore) With(fields []zapcore.Field) zapcore.Core {
+	return &forcedCore{
+		Core:     c.Core.With(fields),
+		instance: c.instance,
+	}
+}
+
+func (c *forcedCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+
+func (c *forcedCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !c.instance.enabledFor(ent.LoggerName, ent.Level) {
+		// fields is shared by every instance of the tee
+		fields = append(fields[:len(fields):len(fields)], zap.Bool("forced", true))
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// terminate syncs every LogInstance, runs the exit hooks and then panics with msg, or calls the exit function if
+// fatal is set.
+func (cfg *loggerConfig) terminate(msg string, fatal bool) {
+	for _, logInstance := range cfg.instances {
+		_ = logInstance.core.Sync()
+	}
+	for _, hook := range cfg.exitHooks {
+		hook()
+	}
+	if !fatal {
+		panic(msg)
+	}
+	if cfg.options != nil && cfg.options.exitFunc != nil {
+		cfg.options.exitFunc(1)
+		return
+	}
+	os.Exit(1)
+}
+
+// terminalHook runs after a Panic, DPanic (in development) or Fatal entry has been written to every enabled
+// LogInstance.
+type terminalHook struct {
+	cfg   *loggerConfig
+	fatal bool
+}
+
+func (h terminalHook) OnWrite(ce *zapcore.CheckedEntry, _ []zapcore.Field) {
+	h.cfg.terminate(ce.Message, h.fatal)
+}
@@ -28,15 +28,77 @@ const (
 )
 
 type LogInstance struct {
-	logger  *zap.Logger
-	level   zap.AtomicLevel
-	enabled *atomic.Bool
+	logger       *zap.Logger
+	core         zapcore.Core
+	level        zap.AtomicLevel
+	components   atomic.Pointer[componentLevels] // set by SetComponentLevel
+	redaction    *atomic.Pointer[redactor]       // shared loggerRoot.redaction
+	pseudonymKey *atomic.Pointer[pseudonymKey]   // shared loggerRoot.pseudonymKey
+	closeCore    func() error                    // stops the goroutines and connections of the core, may be nil
+	metrics      *instanceMetrics
+	stackLevel   zapcore.Level
+	development  bool
+}
+
+// setCore sets the core the LogInstance contributes to the shared dispatch logger and builds the standalone logger
+// used to write to only this instance. Stack traces are captured once by the shared logger at the lowest stack
+// level of all enabled instances, so core is wrapped to drop them below this instance's stackLevel.
+func (l *LogInstance) setCore(core zapcore.Core, stackLevel zapcore.Level, development bool, samplingOpts *SamplingOptions, sanitizeOpts *SanitizeOptions) {
+	if sanitizeOpts != nil {
+		core = newSanitizeCore(core, *sanitizeOpts, l.metrics)
+	}
+	core = &redactCore{
+		Core:         core,
+		redaction:    l.redaction,
+		pseudonymKey: l.pseudonymKey,
+	}
+	core = &stackFilterCore{
+		Core:       core,
+		stackLevel: stackLevel,
+	}
+	core = newDedupCore(core)
+	if samplingOpts != nil {
+		core = zapcore.NewSamplerWithOptions(core, samplingOpts.Tick, samplingOpts.First, samplingOpts.Thereafter)
+	}
+	core = &componentLevelCore{
+		Core:     core,
+		instance: l,
+	}
+	l.core = core
+	l.stackLevel = stackLevel
+	l.development = development
+
+	opts := []zap.Option{zap.AddStacktrace(stackLevel), zap.AddCaller(), zap.AddCallerSkip(1)}
+	if development {
+		opts = append(opts, zap.Development())
+	}
+	l.logger = zap.New(core, opts...)
 }
 
 type Logger struct {
-	startMutex sync.RWMutex // locks start/stop
-	started    bool
-	cfg        atomic.Pointer[loggerConfig]
+	*loggerRoot
+
+	// derive is set on the children returned by Named and With and applied to the compiled logger of each config
+	derive  func(logger *zap.Logger) *zap.Logger
+	derived atomic.Pointer[dispatchLoggers]
+	// withFields are the fields added by With to this Logger and its parents, parents first, and withKeys their keys
+	withFields []Field
+	withKeys   []string
+}
+
+// loggerRoot is the state shared by a Logger and its children.
+type loggerRoot struct {
+	startMutex      sync.RWMutex // locks start/stop
+	started         bool
+	flags           *loggerFlags // set by RegisterFlags, applied by StartTask
+	cfgMutex        sync.Mutex   // serializes config updates
+	cfg             atomic.Pointer[loggerConfig]
+	minLevel        atomic.Int32             // lowest level accepted by any enabled LogInstance
+	componentLevels map[string]zapcore.Level // set by SetComponentLevel, protected by cfgMutex
+	levelRules      atomic.Pointer[levelRules]
+	benignErrors    atomic.Pointer[[]error]      // set by SetBenignErrors
+	redaction       atomic.Pointer[redactor]     // set by SetRedaction
+	pseudonymKey    atomic.Pointer[pseudonymKey] // set by SetPseudonymKey
 }
 
 func (s *Logger) config() *loggerConfig {
@@ -48,8 +110,35 @@ func (s *Logger) config() *loggerConfig {
 	return cfg
 }
 
+// setConfig compiles cfg and makes it the current config. Callers must hold cfgMutex.
 func (s *Logger) setConfig(cfg *loggerConfig) {
+	cfg.compile()
 	s.cfg.Store(cfg)
+	s.refreshMinLevel()
+}
+
+// refreshMinLevel recomputes the lowest level accepted by any enabled LogInstance. Callers must hold cfgMutex.
+func (s *Logger) refreshMinLevel() {
+	minLevel := zapcore.InvalidLevel
+	cfg := s.config()
+	for key, logInstance := range cfg.instances {
+		if !cfg.enabled[key] {
+			continue
+		}
+		if logInstance.level.Level() < minLevel {
+			minLevel = logInstance.level.Level()
+		}
+		if components := logInstance.components.Load(); components != nil && components.minLevel < minLevel {
+			minLevel = components.minLevel
+		}
+	}
+	s.minLevel.Store(int32(minLevel))
+}
+
+// enabled is the fast path check done before any work in the level methods. It reports whether any enabled
+// LogInstance could accept an entry at level.
+func (s *Logger) enabled(level zapcore.Level) bool {
+	return int32(level) >= s.minLevel.Load()
 }
 
 var instance *Logger
@@ -64,67 +153,67 @@ func (s *Logger) StartTask(opts ...LoggingOption) {
 		return
 	}
 
+	s.cfgMutex.Lock()
 	cfg := s.config().clone()
 
 	// apply options
 	for _, opt := range opts {
 		opt(cfg.options)
 	}
+	var samplingOpts *SamplingOptions
+	if cfg.options.samplingEnabled {
+		samplingOpts = &cfg.options.samplingOptions
+	}
+	var sanitizeOpts *SanitizeOptions
+	consoleSanitizeOpts := &defaultConsoleSanitizeOptions
+	if cfg.options.sanitizeEnabled {
+		sanitizeOpts = &cfg.options.sanitizeOptions
+		consoleSanitizeOpts = sanitizeOpts
+	}
 
 	//
 	// debug console logger
 	//
-	cfg.instances[debugConsoleKey] = &LogInstance{
-		enabled: atomic.NewBool(false),
-	}
-	cfg.instances[debugConsoleKey].level = zap.NewAtomicLevelAt(zapcore.Level(InfoLevel))
+	cfg.setInstance(debugConsoleKey, s.newLogInstance(InfoLevel), false)
 
 	consoleEncoderConfig := zap.NewDevelopmentEncoderConfig()
 	//consoleEncoderConfig.FunctionKey = "function"		// uncomment this to enable calling function like: github.com/foo/bar/foo/slogger.(*Logger).ErrorUnstruct
-	consoleEncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	consoleEncoderConfig.EncodeLevel = capitalColorLevelEncoder
 	consoleEncoderConfig.EncodeTime = zapcore.TimeEncoderOfLayout("2006-01-02 15:04:05.000000000 UTCZ07:00")
 
-	cfg.instances[debugConsoleKey].logger = zap.New(
+	cfg.instances[debugConsoleKey].setCore(
 		zapcore.NewCore(
 			zapcore.NewConsoleEncoder(consoleEncoderConfig),
 			zapcore.AddSync(colorable.NewColorableStdout()),
-			cfg.instances[debugConsoleKey].level,
+			cfg.instances[debugConsoleKey].levelEnabler(),
 		),
-		zap.AddCallerSkip(1),
-		zap.Development(),
-		zap.AddCaller(),
-		zap.AddStacktrace(zapcore.WarnLevel),
+		zapcore.WarnLevel,
+		true,
+		nil,
+		consoleSanitizeOpts,
 	)
 
 	//
 	// json stdout logger
 	//
-	cfg.instances[jsonStdoutKey] = &LogInstance{
-		enabled: atomic.NewBool(false),
-	}
-	cfg.instances[jsonStdoutKey].level = zap.NewAtomicLevelAt(zapcore.Level(InfoLevel))
+	cfg.setInstance(jsonStdoutKey, s.newLogInstance(InfoLevel), false)
 
 	jsonStdoutEncoderConfig := zap.NewProductionEncoderConfig()
+	jsonStdoutEncoderConfig.EncodeLevel = jsonLevelEncoder
 	jsonStdoutEncoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 		enc.AppendString(t.UTC().Format(time.RFC3339Nano))
 	}
 	jsonStdoutLoggerCore := zapcore.NewCore(
 		zapcore.NewJSONEncoder(jsonStdoutEncoderConfig),
 		zapcore.AddSync(os.Stdout),
-		cfg.instances[jsonStdoutKey].level,
+		cfg.instances[jsonStdoutKey].levelEnabler(),
 	)
-	if cfg.options.samplingEnabled {
-		jsonStdoutLoggerCore = zapcore.NewSamplerWithOptions(jsonStdoutLoggerCore, cfg.options.samplingOptions.Tick, cfg.options.samplingOptions.First, cfg.options.samplingOptions.Thereafter)
-	}
-	cfg.instances[jsonStdoutKey].logger = zap.New(jsonStdoutLoggerCore, zap.AddStacktrace(zap.ErrorLevel), zap.AddCaller(), zap.AddCallerSkip(1))
+	cfg.instances[jsonStdoutKey].setCore(jsonStdoutLoggerCore, zapcore.ErrorLevel, false, samplingOpts, sanitizeOpts)
 
 	//
 	// file logger
 	//
-	cfg.instances[fileKey] = &LogInstance{
-		enabled: atomic.NewBool(false),
-	}
-	cfg.instances[fileKey].level = zap.NewAtomicLevelAt(zapcore.Level(ErrorLevel))
+	cfg.setInstance(fileKey, s.newLogInstance(ErrorLevel), false)
 
 	var exPath string
 	exPath, err = os.Executable()
@@ -141,6 +230,7 @@ func (s *Logger) StartTask(opts ...LoggingOption) {
 	})
 
 	fileEncoderConfig := zap.NewProductionEncoderConfig()
+	fileEncoderConfig.EncodeLevel = jsonLevelEncoder
 	fileEncoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 		enc.AppendString(t.UTC().Format(time.RFC3339Nano))
 	}
@@ -148,25 +238,35 @@ func (s *Logger) StartTask(opts ...LoggingOption) {
 	fileLoggerCore := zapcore.NewCore(
 		zapcore.NewJSONEncoder(fileEncoderConfig),
 		lumberjackSink,
-		cfg.instances[fileKey].level,
+		cfg.instances[fileKey].levelEnabler(),
 	)
+	cfg.instances[fileKey].setCore(fileLoggerCore, zapcore.ErrorLevel, false, samplingOpts, sanitizeOpts)
 
-	if cfg.options.samplingEnabled {
-		fileLoggerCore = zapcore.NewSamplerWithOptions(fileLoggerCore, cfg.options.samplingOptions.Tick, cfg.options.samplingOptions.First, cfg.options.samplingOptions.Thereafter)
+	//
+	// flight recorder
+	//
+	cfg.flightRecorder = nil
+	if cfg.options.flightRecorderEnabled {
+		cfg.flightRecorder = newFlightRecorderRing(s, cfg.options.flightRecorderOptions)
+	}
+
+	if s.flags != nil {
+		s.flags.apply(cfg)
 	}
-	cfg.instances[fileKey].logger = zap.New(fileLoggerCore, zap.AddStacktrace(zap.ErrorLevel), zap.AddCaller(), zap.AddCallerSkip(1))
 
 	s.setConfig(cfg)
+	s.cfgMutex.Unlock()
 
 	s.started = true
 	s.startMutex.Unlock()
 	s.Info(getTaskLogPrefix(taskName, "started"))
 }
 
+// Sync flushes every LogInstance. Instances added WithAsync first wait for their queue to drain.
 func (s *Logger) Sync() {
 	cfg := s.config()
 	for _, logInstance := range cfg.instances {
-		_ = logInstance.logger.Sync()
+		_ = logInstance.core.Sync()
 	}
 }
 
@@ -181,6 +281,22 @@ func (s *Logger) StopTask() {
 	s.startMutex.Unlock()
 	s.Sync()
 	s.Info(getTaskLogPrefix(taskName, "stopped"))
+
+	// close the instances with background goroutines, they are added again after a restart
+	s.cfgMutex.Lock()
+	cfg := s.config().clone()
+	var closing []*LogInstance
+	for key, logInstance := range cfg.instances {
+		if logInstance.closeCore != nil {
+			closing = append(closing, logInstance)
+			cfg.removeInstance(key)
+		}
+	}
+	s.setConfig(cfg)
+	s.cfgMutex.Unlock()
+	for _, logInstance := range closing {
+		_ = logInstance.closeCore()
+	}
 }
 
 // Instance deprecated
@@ -192,9 +308,14 @@ func Instance() *Logger {
 }
 
 func NewLogger() *Logger {
-	logger := new(Logger)
+	logger := &Logger{
+		loggerRoot: new(loggerRoot),
+	}
+	logger.cfgMutex.Lock()
+	defer logger.cfgMutex.Unlock()
 	logger.setConfig(&loggerConfig{
 		instances: make(map[string]*LogInstance),
+		enabled:   make(map[string]bool),
 		options: &Options{
 			productNameShort: DefaultAppShortName,
 		},
@@ -203,6 +324,30 @@ func NewLogger() *Logger {
 }
 
 func (s *Logger) AddLogger(key string, w io.Writer, newLevel Level, opts ...LoggingOption) {
+	s.addInstance(key, newLevel, func(level zapcore.LevelEnabler, _ *Options) (zapcore.Core, func() error) {
+		encoderConfig := zap.NewProductionEncoderConfig()
+		encoderConfig.EncodeLevel = jsonLevelEncoder
+		encoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString(t.UTC().Format(time.RFC3339Nano))
+		}
+
+		return zapcore.NewCore(
+			zapcore.NewJSONEncoder(encoderConfig),
+			zapcore.AddSync(w),
+			level,
+		), nil
+	}, opts...)
+}
+
+// instanceCoreBuilder creates the zapcore.Core for a new LogInstance. It is only called when no instance exists at
+// the key, so builders are free to start connections or goroutines; they return a closeCore that stops them, or nil.
+type instanceCoreBuilder func(level zapcore.LevelEnabler, addLoggerOpts *Options) (core zapcore.Core, closeCore func() error)
+
+// addInstance adds a LogInstance at key with the core returned by newCore. If a logger already exists at this key
+// it does nothing.
+func (s *Logger) addInstance(key string, newLevel Level, newCore instanceCoreBuilder, opts ...LoggingOption) {
+	s.cfgMutex.Lock()
+	defer s.cfgMutex.Unlock()
 	cfg := s.config()
 	// if a logger already exists at this key do nothing
 	_, exists := cfg.instances[key]
@@ -217,34 +362,84 @@ func (s *Logger) AddLogger(key string, w io.Writer, newLevel Level, opts ...Logg
 		opt(&addLoggerOpts)
 	}
 
-	cfg.instances[key] = &LogInstance{
-		enabled: atomic.NewBool(true),
-	}
-	cfg.instances[key].level = zap.NewAtomicLevelAt(zapcore.Level(newLevel))
+	cfg.setInstance(key, s.newLogInstance(newLevel), true)
 
-	encoderConfig := zap.NewProductionEncoderConfig()
-	encoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
-		enc.AppendString(t.UTC().Format(time.RFC3339Nano))
+	newloggerCore, closeCore := newCore(cfg.instances[key].levelEnabler(), &addLoggerOpts)
+	cfg.instances[key].closeCore = closeCore
+	if addLoggerOpts.asyncEnabled {
+		var closeQueue func() error
+		newloggerCore, closeQueue = newAsyncCore(newloggerCore, addLoggerOpts.asyncOptions, cfg.instances[key].metrics)
+		cfg.instances[key].closeCore = chainClose(closeQueue, closeCore)
 	}
-
-	newloggerCore := zapcore.NewCore(
-		zapcore.NewJSONEncoder(encoderConfig),
-		zapcore.AddSync(w),
-		cfg.instances[key].level,
-	)
+	var samplingOpts *SamplingOptions
 	if addLoggerOpts.samplingEnabled {
-		newloggerCore = zapcore.NewSamplerWithOptions(newloggerCore, cfg.options.samplingOptions.Tick, cfg.options.samplingOptions.First, cfg.options.samplingOptions.Thereafter)
+		samplingOpts = &cfg.options.samplingOptions
 	}
+	var sanitizeOpts *SanitizeOptions
+	if addLoggerOpts.sanitizeEnabled {
+		sanitizeOpts = &addLoggerOpts.sanitizeOptions
+	}
+	cfg.instances[key].setCore(newloggerCore, zapcore.ErrorLevel, false, samplingOpts, sanitizeOpts)
 
-	cfg.instances[key].logger = zap.New(newloggerCore, zap.AddStacktrace(zap.ErrorLevel), zap.AddCaller(), zap.AddCallerSkip(1))
+	s.setConfig(cfg)
+}
+
+// chainClose returns a func that calls first and then next, which may be nil, returning the first error.
+func chainClose(first func() error, next func() error) func() error {
+	if next == nil {
+		return first
+	}
+	return func() error {
+		err := first()
+		if nextErr := next(); err == nil {
+			err = nextErr
+		}
+		return err
+	}
+}
 
+// RemoveLogger removes the LogInstance at key, flushing and closing it. It does nothing if there is no instance at
+// key.
+func (s *Logger) RemoveLogger(key string) {
+	s.cfgMutex.Lock()
+	cfg := s.config()
+	logInstance, exists := cfg.instances[key]
+	if !exists {
+		s.cfgMutex.Unlock()
+		return
+	}
+	cfg = cfg.clone()
+	cfg.removeInstance(key)
 	s.setConfig(cfg)
+	s.cfgMutex.Unlock()
+
+	_ = logInstance.core.Sync()
+	if logInstance.closeCore != nil {
+		_ = logInstance.closeCore()
+	}
+}
+
+// newLogInstance creates a LogInstance with the component levels set by SetComponentLevel. Callers must hold
+// cfgMutex.
+func (s *Logger) newLogInstance(level Level) (logInstance *LogInstance) {
+	logInstance = &LogInstance{
+		level:        zap.NewAtomicLevelAt(zapcore.Level(level)),
+		metrics:      new(instanceMetrics),
+		redaction:    &s.redaction,
+		pseudonymKey: &s.pseudonymKey,
+	}
+	logInstance.components.Store(newComponentLevels(s.componentLevels))
+	return
 }
 
 func (s *Logger) SetLoggerEnabled(key string, enabled bool) {
+	s.cfgMutex.Lock()
+	defer s.cfgMutex.Unlock()
 	cfg := s.config()
-	if cfg.instances[key] != nil {
-		cfg.instances[key].enabled.Store(enabled)
+	if cfg.instances[key] != nil && cfg.enabled[key] != enabled {
+		cfg = cfg.clone()
+		cfg.enabled[key] = enabled
+		s.setConfig(cfg)
 	}
 }
 
@@ -261,44 +456,48 @@ func (s *Logger) SetFileLogging(enabled bool) {
 }
 
 func (s *Logger) SetFileLogLevel(newLevel Level) {
-	cfg := s.config()
-	if cfg.instances[fileKey] != nil {
-		cfg.instances[fileKey].level.SetLevel(zapcore.Level(newLevel))
-	}
+	s.SetLogLevel(fileKey, newLevel)
 }
 
 func (s *Logger) SetConsoleLogLevel(newLevel Level) {
-	cfg := s.config()
-	if cfg.instances[debugConsoleKey] != nil {
-		cfg.instances[debugConsoleKey].level.SetLevel(zapcore.Level(newLevel))
-	}
+	s.SetLogLevel(debugConsoleKey, newLevel)
 }
 
 func (s *Logger) SetJsonStdoutLogLevel(newLevel Level) {
-	cfg := s.config()
-	if cfg.instances[jsonStdoutKey] != nil {
-		cfg.instances[jsonStdoutKey].level.SetLevel(zapcore.Level(newLevel))
-	}
+	s.SetLogLevel(jsonStdoutKey, newLevel)
 }
 
 func (s *Logger) SetLogLevel(key string, newLevel Level) {
+	s.cfgMutex.Lock()
+	defer s.cfgMutex.Unlock()
 	cfg := s.config()
 	if cfg.instances[key] != nil {
 		cfg.instances[key].level.SetLevel(zapcore.Level(newLevel))
+		s.refreshMinLevel()
 	}
 }
 
+// RegisterExitHook adds a function that is run after a Panic or Fatal entry has been written to and synced on every
+// enabled LogInstance, before the process panics or exits.
+func (s *Logger) RegisterExitHook(hook func()) {
+	s.cfgMutex.Lock()
+	defer s.cfgMutex.Unlock()
+	cfg := s.config().clone()
+	cfg.exitHooks = append(cfg.exitHooks, hook)
+	s.setConfig(cfg)
+}
+
 // ErrorInLoggerWriter is used by log Writer sinks added with AddLogger() to log messages to standard console & file instances
 // that are enabled so the error in the logger can be trapped somewhere and without an error loop in the logger that triggered it
 func (s *Logger) ErrorInLoggerWriter(format string, args ...interface{}) {
 	cfg := s.config()
-	if cfg.instances[fileKey] != nil && cfg.instances[fileKey].enabled.Load() {
+	if cfg.enabled[fileKey] {
 		cfg.instances[fileKey].logger.Sugar().Errorf(format, args...)
 	}
-	if cfg.instances[debugConsoleKey] != nil && cfg.instances[debugConsoleKey].enabled.Load() {
+	if cfg.enabled[debugConsoleKey] {
 		cfg.instances[debugConsoleKey].logger.Sugar().Errorf(format, args...)
 	}
-	if cfg.instances[jsonStdoutKey] != nil && cfg.instances[jsonStdoutKey].enabled.Load() {
+	if cfg.enabled[jsonStdoutKey] {
 		cfg.instances[jsonStdoutKey].logger.Sugar().Errorf(format, args...)
 	}
 }
@@ -307,7 +506,7 @@ func (s *Logger) ErrorInLoggerWriterIgnoreCancel(ctx context.Context, format str
 	if ctx.Err() != nil {
 		return
 	}
-	s.ErrorInLoggerWriter(format, args)
+	s.ErrorInLoggerWriter(format, args...)
 }
 
 func (s *Logger) IsLevelEnabled(level Level) bool {
@@ -331,10 +530,14 @@ func (s *Logger) IsLevelEnabled(level Level) bool {
 //	return status.Signal() == syscall.SIGINT
 //}
 
+// fieldsToZapFields converts fields and resolves Lazy fields. It is called once an entry passed the level check.
 func fieldsToZapFields(fields ...Field) []zap.Field {
-	var zapFields []zap.Field
-	for _, f := range fields {
-		zapFields = append(zapFields, zap.Field(f))
+	if len(fields) == 0 {
+		return nil
+	}
+	zapFields := make([]zap.Field, len(fields))
+	for i := range fields {
+		zapFields[i] = zap.Field(resolveLazy(fields[i]))
 	}
 	return zapFields
 }
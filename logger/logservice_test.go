@@ -0,0 +1,23 @@
+package logger
+
+import (
+	"testing"
+)
+
+func TestSetLoggerEnabledKeepsPublishedConfig(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	published := s.config()
+
+	s.SetLoggerEnabled("test", false)
+	if !published.enabled["test"] {
+		t.Fatal("SetLoggerEnabled changed a published config")
+	}
+	s.Info("disabled")
+	s.SetLoggerEnabled("test", true)
+	s.Info("enabled")
+
+	lines := buf.lines()
+	if len(lines) != 1 || lastEntry(t, buf)["msg"] != "enabled" {
+		t.Fatalf("unexpected entries: %q", lines)
+	}
+}
@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"go.uber.org/atomic"
+)
+
+// InstanceMetrics are counters a LogInstance keeps about the logger itself.
+type InstanceMetrics struct {
+	// Dropped is the number of entries dropped because an async queue was full.
+	Dropped uint64
+	// Truncated is the number of messages and fields cut by the SanitizeOptions limits.
+	Truncated uint64
+}
+
+type instanceMetrics struct {
+	dropped   atomic.Uint64
+	truncated atomic.Uint64
+}
+
+func (m *instanceMetrics) snapshot() InstanceMetrics {
+	return InstanceMetrics{
+		Dropped:   m.dropped.Load(),
+		Truncated: m.truncated.Load(),
+	}
+}
+
+// InstanceMetrics returns the self-metrics of the LogInstance at key.
+func (s *Logger) InstanceMetrics(key string) (metrics InstanceMetrics, found bool) {
+	cfg := s.config()
+	if cfg.instances[key] != nil && cfg.instances[key].metrics != nil {
+		metrics = cfg.instances[key].metrics.snapshot()
+		found = true
+	}
+	return
+}
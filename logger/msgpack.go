@@ -0,0 +1,368 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// Minimal MessagePack (https://github.com/msgpack/msgpack/blob/master/spec.md) support for the wire formats used by
+// network sinks. Only the subset of types produced by zapcore.MapObjectEncoder is encoded, and the decoder only needs
+// to understand simple responses like Fluentd acks.
+
+const msgpackExtEventTime = 0x00
+
+var errMsgpackUnsupported = errors.New("msgpack: unsupported type")
+
+func appendMsgpackNil(b []byte) []byte {
+	return append(b, 0xc0)
+}
+
+func appendMsgpackBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xc3)
+	}
+	return append(b, 0xc2)
+}
+
+func appendMsgpackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return appendMsgpackUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v))
+	}
+}
+
+func appendMsgpackUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= 0x7f:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), v)
+	}
+}
+
+func appendMsgpackFloat64(b []byte, v float64) []byte {
+	return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v))
+}
+
+func appendMsgpackString(b []byte, v string) []byte {
+	l := len(v)
+	switch {
+	case l <= 31:
+		b = append(b, 0xa0|byte(l))
+	case l <= math.MaxUint8:
+		b = append(b, 0xd9, byte(l))
+	case l <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(l))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(l))
+	}
+	return append(b, v...)
+}
+
+func appendMsgpackBinary(b []byte, v []byte) []byte {
+	return append(appendMsgpackBinaryHeader(b, len(v)), v...)
+}
+
+func appendMsgpackBinaryHeader(b []byte, l int) []byte {
+	switch {
+	case l <= math.MaxUint8:
+		return append(b, 0xc4, byte(l))
+	case l <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(l))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(l))
+	}
+}
+
+func appendMsgpackArrayHeader(b []byte, l int) []byte {
+	switch {
+	case l <= 15:
+		return append(b, 0x90|byte(l))
+	case l <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xdc), uint16(l))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdd), uint32(l))
+	}
+}
+
+func appendMsgpackMapHeader(b []byte, l int) []byte {
+	switch {
+	case l <= 15:
+		return append(b, 0x80|byte(l))
+	case l <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xde), uint16(l))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdf), uint32(l))
+	}
+}
+
+// appendMsgpackEventTime appends t as a Fluentd EventTime extension (fixext 8, type 0).
+func appendMsgpackEventTime(b []byte, t time.Time) []byte {
+	b = append(b, 0xd7, msgpackExtEventTime)
+	b = binary.BigEndian.AppendUint32(b, uint32(t.Unix()))
+	return binary.BigEndian.AppendUint32(b, uint32(t.Nanosecond()))
+}
+
+// appendMsgpack appends v in MessagePack format. Values that have no natural MessagePack representation are encoded
+// the way the JSON encoders in this package would represent them.
+func appendMsgpack(b []byte, v interface{}) []byte {
+	switch val := v.(type) {
+	case nil:
+		return appendMsgpackNil(b)
+	case bool:
+		return appendMsgpackBool(b, val)
+	case int:
+		return appendMsgpackInt(b, int64(val))
+	case int8:
+		return appendMsgpackInt(b, int64(val))
+	case int16:
+		return appendMsgpackInt(b, int64(val))
+	case int32:
+		return appendMsgpackInt(b, int64(val))
+	case int64:
+		return appendMsgpackInt(b, val)
+	case uint:
+		return appendMsgpackUint(b, uint64(val))
+	case uint8:
+		return appendMsgpackUint(b, uint64(val))
+	case uint16:
+		return appendMsgpackUint(b, uint64(val))
+	case uint32:
+		return appendMsgpackUint(b, uint64(val))
+	case uint64:
+		return appendMsgpackUint(b, val)
+	case uintptr:
+		return appendMsgpackUint(b, uint64(val))
+	case float32:
+		return appendMsgpackFloat64(b, float64(val))
+	case float64:
+		return appendMsgpackFloat64(b, val)
+	case complex64, complex128:
+		return appendMsgpackString(b, fmt.Sprint(val))
+	case string:
+		return appendMsgpackString(b, val)
+	case []byte:
+		return appendMsgpackBinary(b, val)
+	case time.Time:
+		return appendMsgpackString(b, val.UTC().Format(time.RFC3339Nano))
+	case time.Duration:
+		return appendMsgpackFloat64(b, val.Seconds())
+	case error:
+		return appendMsgpackString(b, val.Error())
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = appendMsgpackMapHeader(b, len(keys))
+		for _, k := range keys {
+			b = appendMsgpackString(b, k)
+			b = appendMsgpack(b, val[k])
+		}
+		return b
+	case []interface{}:
+		b = appendMsgpackArrayHeader(b, len(val))
+		for _, elem := range val {
+			b = appendMsgpack(b, elem)
+		}
+		return b
+	case fmt.Stringer:
+		return appendMsgpackString(b, val.String())
+	default:
+		// round trip anything else (reflected objects) through JSON to get a generic representation
+		var generic interface{}
+		jsonBytes, err := json.Marshal(val)
+		if err == nil {
+			err = json.Unmarshal(jsonBytes, &generic)
+		}
+		if err != nil {
+			return appendMsgpackString(b, fmt.Sprintf("%+v", val))
+		}
+		return appendMsgpack(b, generic)
+	}
+}
+
+// readMsgpack decodes a single MessagePack value from r. Maps are decoded to map[string]interface{} and extension
+// types are returned as raw []byte.
+func readMsgpack(r *bufio.Reader) (interface{}, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return readMsgpackMap(r, int(c&0x0f))
+	case c&0xf0 == 0x90:
+		return readMsgpackArray(r, int(c&0x0f))
+	case c&0xe0 == 0xa0:
+		return readMsgpackString(r, int(c&0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xd9:
+		l, err := readMsgpackLen(r, 1)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackStringOrBinary(r, l, c == 0xc4)
+	case 0xc5, 0xda:
+		l, err := readMsgpackLen(r, 2)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackStringOrBinary(r, l, c == 0xc5)
+	case 0xc6, 0xdb:
+		l, err := readMsgpackLen(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackStringOrBinary(r, l, c == 0xc6)
+	case 0xca:
+		raw, err := readMsgpackBytes(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), nil
+	case 0xcb:
+		raw, err := readMsgpackBytes(r, 8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		raw, err := readMsgpackBytes(r, 1<<(c-0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return int64(readBigEndian(raw)), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (c - 0xd0)
+		raw, err := readMsgpackBytes(r, n)
+		if err != nil {
+			return nil, err
+		}
+		// sign extend
+		shift := 64 - 8*n
+		return int64(readBigEndian(raw)<<shift) >> shift, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		// fixext: type byte followed by 1, 2, 4, 8 or 16 bytes of data
+		return readMsgpackBytes(r, 1+1<<(c-0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		l, err := readMsgpackLen(r, 1<<(c-0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackBytes(r, l+1)
+	case 0xdc, 0xdd:
+		l, err := readMsgpackLen(r, 2<<(c-0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackArray(r, l)
+	case 0xde, 0xdf:
+		l, err := readMsgpackLen(r, 2<<(c-0xde))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackMap(r, l)
+	}
+	return nil, fmt.Errorf("%w: 0x%x", errMsgpackUnsupported, c)
+}
+
+func readBigEndian(raw []byte) (v uint64) {
+	for _, c := range raw {
+		v = v<<8 | uint64(c)
+	}
+	return
+}
+
+func readMsgpackBytes(r *bufio.Reader, n int) ([]byte, error) {
+	raw := make([]byte, n)
+	_, err := io.ReadFull(r, raw)
+	return raw, err
+}
+
+func readMsgpackLen(r *bufio.Reader, n int) (int, error) {
+	raw, err := readMsgpackBytes(r, n)
+	if err != nil {
+		return 0, err
+	}
+	return int(readBigEndian(raw)), nil
+}
+
+func readMsgpackString(r *bufio.Reader, l int) (interface{}, error) {
+	return readMsgpackStringOrBinary(r, l, false)
+}
+
+func readMsgpackStringOrBinary(r *bufio.Reader, l int, isBinary bool) (interface{}, error) {
+	raw, err := readMsgpackBytes(r, l)
+	if err != nil {
+		return nil, err
+	}
+	if isBinary {
+		return raw, nil
+	}
+	return string(raw), nil
+}
+
+func readMsgpackArray(r *bufio.Reader, l int) (interface{}, error) {
+	arr := make([]interface{}, 0, l)
+	for i := 0; i < l; i++ {
+		elem, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, elem)
+	}
+	return arr, nil
+}
+
+func readMsgpackMap(r *bufio.Reader, l int) (interface{}, error) {
+	m := make(map[string]interface{}, l)
+	for i := 0; i < l; i++ {
+		k, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(k)] = v
+	}
+	return m, nil
+}
@@ -0,0 +1,266 @@
+package logger
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"sort"
+	"strings"
+)
+
+// dispatchLoggers are the loggers compiled from a config. Children of a Logger derive their own once per config with
+// their name and fields applied.
+type dispatchLoggers struct {
+	cfg    *loggerConfig
+	logger *zap.Logger
+	sugar  *zap.SugaredLogger
+	// forced ignores instance levels, see forcedCore
+	forced *zap.Logger
+}
+
+// loggersOf returns the dispatch loggers of cfg for s.
+func (s *Logger) loggersOf(cfg *loggerConfig) *dispatchLoggers {
+	if s.derive == nil {
+		return cfg.dispatch
+	}
+	derived := s.derived.Load()
+	if derived == nil || derived.cfg != cfg {
+		derived = &dispatchLoggers{
+			cfg:    cfg,
+			logger: s.derive(cfg.dispatch.logger),
+			forced: s.derive(cfg.dispatch.forced),
+		}
+		derived.sugar = derived.logger.WithOptions(zap.AddCallerSkip(-1)).Sugar()
+		s.derived.Store(derived)
+	}
+	return derived
+}
+
+func (s *Logger) sugar() *zap.SugaredLogger {
+	return s.loggersOf(s.config()).sugar
+}
+
+// Named returns a child Logger that adds a "logger" field with the dotted path of names from the root, e.g.
+// Named("storage").Named("compaction") logs as "storage.compaction". The child shares the instances, levels and
+// config of its parent. Levels can be overridden per component with SetComponentLevel.
+func (s *Logger) Named(name string) *Logger {
+	if name == "" {
+		return s
+	}
+	return s.child(func(logger *zap.Logger) *zap.Logger {
+		return logger.Named(name)
+	})
+}
+
+// With returns a child Logger that adds fields to every entry, for long-lived structs that log without a context.
+// The fields are encoded once by each LogInstance when the child is first used with a config, not on every call, and
+// Lazy fields are computed once when With is called. The child shares the instances, levels and config of its parent.
+func (s *Logger) With(fields ...Field) *Logger {
+	if len(fields) == 0 {
+		return s
+	}
+	resolved := make([]Field, len(fields))
+	for i := range fields {
+		resolved[i] = resolveLazy(fields[i])
+	}
+	zapFields := fieldsToZapFields(resolved...)
+	child := s.child(func(logger *zap.Logger) *zap.Logger {
+		return logger.With(zapFields...)
+	})
+	child.withFields = append(child.withFields, resolved...)
+	var duplicates []string
+	for _, field := range fields {
+		if field.Key == "" {
+			continue
+		}
+		if containsKey(child.withKeys, field.Key) {
+			duplicates = appendKey(duplicates, field.Key)
+		}
+		child.withKeys = appendKey(child.withKeys, field.Key)
+	}
+	if len(duplicates) > 0 && s.config().options.strictDuplicateKeys {
+		s.ErrorInLoggerWriter("duplicate log field keys %v in With", duplicates)
+	}
+	return child
+}
+
+// child returns a Logger sharing the root of s that applies derive after the derivations of s.
+func (s *Logger) child(derive func(logger *zap.Logger) *zap.Logger) *Logger {
+	if parentDerive := s.derive; parentDerive != nil {
+		childDerive := derive
+		derive = func(logger *zap.Logger) *zap.Logger {
+			return childDerive(parentDerive(logger))
+		}
+	}
+	return &Logger{
+		loggerRoot: s.loggerRoot,
+		derive:     derive,
+		withFields: append([]Field(nil), s.withFields...),
+		withKeys:   append([]string(nil), s.withKeys...),
+	}
+}
+
+// WithName stores a child of the Logger in ctx named with name, so the ContextLogger returned by Of is subject to
+// the component levels of the name. It returns ctx unchanged if ctx has no Logger.
+func WithName(ctx context.Context, name string) context.Context {
+	logger, loggerExists := ctx.Value(loggerContextKey).(*Logger)
+	if !loggerExists {
+		return ctx
+	}
+	return WithLogger(ctx, logger.Named(name))
+}
+
+// SetComponentLevel overrides the level of every LogInstance for entries from the Logger named prefix and its
+// descendants, e.g. "storage" applies to "storage" and "storage.compaction" but not "storagex". The longest matching
+// prefix wins. It also applies to instances added later.
+func (s *Logger) SetComponentLevel(prefix string, newLevel Level) {
+	s.cfgMutex.Lock()
+	defer s.cfgMutex.Unlock()
+	if s.componentLevels == nil {
+		s.componentLevels = make(map[string]zapcore.Level)
+	}
+	s.componentLevels[prefix] = zapcore.Level(newLevel)
+	for _, logInstance := range s.config().instances {
+		logInstance.setComponentLevel(prefix, zapcore.Level(newLevel))
+	}
+	s.refreshMinLevel()
+}
+
+// SetComponentLogLevel overrides the level of the LogInstance at key for entries from the Logger named prefix and
+// its descendants.
+func (s *Logger) SetComponentLogLevel(key string, prefix string, newLevel Level) {
+	s.cfgMutex.Lock()
+	defer s.cfgMutex.Unlock()
+	if logInstance := s.config().instances[key]; logInstance != nil {
+		logInstance.setComponentLevel(prefix, zapcore.Level(newLevel))
+		s.refreshMinLevel()
+	}
+}
+
+// RemoveComponentLevel removes the override for prefix from every LogInstance.
+func (s *Logger) RemoveComponentLevel(prefix string) {
+	s.cfgMutex.Lock()
+	defer s.cfgMutex.Unlock()
+	delete(s.componentLevels, prefix)
+	for _, logInstance := range s.config().instances {
+		logInstance.removeComponentLevel(prefix)
+	}
+	s.refreshMinLevel()
+}
+
+// componentLevels are the component level overrides of a LogInstance. It is replaced, never modified, so it can be
+// read without locking.
+type componentLevels struct {
+	levels map[string]zapcore.Level
+	// prefixes sorted by descending length so the first match is the longest
+	prefixes []string
+	minLevel zapcore.Level
+}
+
+// newComponentLevels returns nil if there are no levels.
+func newComponentLevels(levels map[string]zapcore.Level) *componentLevels {
+	if len(levels) == 0 {
+		return nil
+	}
+	c := &componentLevels{
+		levels:   make(map[string]zapcore.Level, len(levels)),
+		prefixes: make([]string, 0, len(levels)),
+		minLevel: zapcore.InvalidLevel,
+	}
+	for prefix, level := range levels {
+		c.levels[prefix] = level
+		c.prefixes = append(c.prefixes, prefix)
+		if level < c.minLevel {
+			c.minLevel = level
+		}
+	}
+	sort.Slice(c.prefixes, func(i, j int) bool {
+		return len(c.prefixes[i]) > len(c.prefixes[j])
+	})
+	return c
+}
+
+// levelOf returns the level of the longest prefix matching name.
+func (c *componentLevels) levelOf(name string) (level zapcore.Level, found bool) {
+	for _, prefix := range c.prefixes {
+		if name == prefix || (strings.HasPrefix(name, prefix) && name[len(prefix)] == '.') {
+			return c.levels[prefix], true
+		}
+	}
+	return
+}
+
+// setComponentLevel and removeComponentLevel must be called with cfgMutex held.
+func (l *LogInstance) setComponentLevel(prefix string, level zapcore.Level) {
+	levels := make(map[string]zapcore.Level)
+	if components := l.components.Load(); components != nil {
+		for k, v := range components.levels {
+			levels[k] = v
+		}
+	}
+	levels[prefix] = level
+	l.components.Store(newComponentLevels(levels))
+}
+
+func (l *LogInstance) removeComponentLevel(prefix string) {
+	components := l.components.Load()
+	if components == nil {
+		return
+	}
+	levels := make(map[string]zapcore.Level, len(components.levels))
+	for k, v := range components.levels {
+		if k != prefix {
+			levels[k] = v
+		}
+	}
+	l.components.Store(newComponentLevels(levels))
+}
+
+// enabledFor reports whether the LogInstance accepts an entry at level from the Logger named name.
+func (l *LogInstance) enabledFor(name string, level zapcore.Level) bool {
+	if components := l.components.Load(); components != nil {
+		if componentLevel, found := components.levelOf(name); found {
+			return level >= componentLevel
+		}
+	}
+	return l.level.Enabled(level)
+}
+
+// levelEnabler returns the zapcore.LevelEnabler used by the cores of the LogInstance. It also accepts the levels of
+// the component overrides, the exact check by name is done by componentLevelCore.
+func (l *LogInstance) levelEnabler() zapcore.LevelEnabler {
+	return instanceLevelEnabler{instance: l}
+}
+
+type instanceLevelEnabler struct {
+	instance *LogInstance
+}
+
+func (e instanceLevelEnabler) Enabled(level zapcore.Level) bool {
+	if e.instance.level.Enabled(level) {
+		return true
+	}
+	components := e.instance.components.Load()
+	return components != nil && level >= components.minLevel
+}
+
+// componentLevelCore is the outermost core of a LogInstance. It checks entries against the instance level or the
+// component level matching the entry's logger name.
+type componentLevelCore struct {
+	zapcore.Core
+	instance *LogInstance
+}
+
+func (c *componentLevelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &componentLevelCore{
+		Core:     c.Core.With(fields),
+		instance: c.instance,
+	}
+}
+
+func (c *componentLevelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.instance.enabledFor(ent.LoggerName, ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// loggerNames returns the logger name and message of the entries written to buf.
+func loggerNames(t *testing.T, buf *syncBuffer) (messages []string) {
+	t.Helper()
+	for _, entry := range buf.entries(t) {
+		name, _ := entry["logger"].(string)
+		messages = append(messages, name+":"+entry["msg"].(string))
+	}
+	return
+}
+
+func TestComponentLevelLongestPrefix(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	s.SetComponentLevel("storage", DebugLevel)
+	s.SetComponentLevel("storage.db", WarnLevel)
+
+	s.Named("storage").Debug("storage debug")
+	s.Named("storage").Named("compaction").Debug("compaction debug")
+	s.Named("storage").Named("db").Info("db info")
+	s.Named("storage").Named("db").Warn("db warn")
+	s.Named("storagex").Debug("storagex debug")
+	s.Named("storagex").Info("storagex info")
+	s.Debug("root debug")
+
+	want := []string{
+		"storage:storage debug",
+		"storage.compaction:compaction debug",
+		"storage.db:db warn",
+		"storagex:storagex info",
+	}
+	if got := loggerNames(t, buf); !reflect.DeepEqual(got, want) {
+		t.Fatalf("entries = %q, want %q", got, want)
+	}
+}
+
+func TestRemoveComponentLevel(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	storage := s.Named("storage")
+	s.SetComponentLevel("storage", DebugLevel)
+	storage.Debug("overridden")
+	s.RemoveComponentLevel("storage")
+	storage.Debug("cleared")
+	storage.Info("info")
+
+	if want := []string{"storage:overridden", "storage:info"}; !reflect.DeepEqual(loggerNames(t, buf), want) {
+		t.Fatalf("entries = %q, want %q", loggerNames(t, buf), want)
+	}
+	if s.Named("storage").Check(DebugLevel, "debug") != nil {
+		t.Fatal("Check(DebugLevel) after the component level was removed")
+	}
+}
+
+func TestWithName(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	s.SetComponentLevel("http.handler", DebugLevel)
+	ctx := WithName(WithName(WithLogger(context.Background(), s), "http"), "handler")
+	OfMust(ctx).Debug("named debug")
+	OfMust(WithName(WithLogger(context.Background(), s), "http")).Debug("parent debug")
+
+	if want := []string{"http.handler:named debug"}; !reflect.DeepEqual(loggerNames(t, buf), want) {
+		t.Fatalf("entries = %q, want %q", loggerNames(t, buf), want)
+	}
+	if bare := context.Background(); WithName(bare, "http") != bare {
+		t.Fatal("WithName changed a context without a Logger")
+	}
+}
@@ -8,6 +8,19 @@ type Options struct {
 	productNameShort string
 	samplingEnabled  bool
 	samplingOptions  SamplingOptions
+	spoolEnabled     bool
+	spoolOptions     SpoolOptions
+	asyncEnabled     bool
+	asyncOptions     AsyncOptions
+	exitFunc         func(code int)
+
+	flightRecorderEnabled bool
+	flightRecorderOptions FlightRecorderOptions
+
+	sanitizeEnabled bool
+	sanitizeOptions SanitizeOptions
+
+	strictDuplicateKeys bool
 }
 
 func (o *Options) clone() *Options {
@@ -15,6 +28,19 @@ func (o *Options) clone() *Options {
 		productNameShort: o.productNameShort,
 		samplingEnabled:  o.samplingEnabled,
 		samplingOptions:  o.samplingOptions,
+		spoolEnabled:     o.spoolEnabled,
+		spoolOptions:     o.spoolOptions,
+		asyncEnabled:     o.asyncEnabled,
+		asyncOptions:     o.asyncOptions,
+		exitFunc:         o.exitFunc,
+
+		flightRecorderEnabled: o.flightRecorderEnabled,
+		flightRecorderOptions: o.flightRecorderOptions,
+
+		sanitizeEnabled: o.sanitizeEnabled,
+		sanitizeOptions: o.sanitizeOptions,
+
+		strictDuplicateKeys: o.strictDuplicateKeys,
 	}
 }
 
@@ -49,3 +75,13 @@ func WithSampling(samplingOptions SamplingOptions) LoggingOption {
 		o.samplingEnabled = true
 	}
 }
+
+// WithExitFunc replaces os.Exit as the function called after a Fatal entry has been written, synced and the exit
+// hooks have run. It is intended for tests.
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithExitFunc(exitFunc func(code int)) LoggingOption {
+	return func(o *Options) {
+		o.exitFunc = exitFunc
+	}
+}
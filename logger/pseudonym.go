@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// pseudonymKey is the HMAC key set by SetPseudonymKey.
+type pseudonymKey struct {
+	id  string
+	key []byte
+}
+
+// SetPseudonymKey sets the key used for Pseudonym fields. Pseudonyms are prefixed with keyID so the key can be rotated
+// by setting a new key with a new id; pseudonyms written before the rotation can still be re-derived with the old key.
+// example: logger.Instance().SetPseudonymKey("2024-06", key)
+func (s *Logger) SetPseudonymKey(keyID string, key []byte) {
+	keyCopy := make([]byte, len(key))
+	copy(keyCopy, key)
+	s.cfgMutex.Lock()
+	defer s.cfgMutex.Unlock()
+	s.pseudonymKey.Store(&pseudonymKey{id: keyID, key: keyCopy})
+	// recompile so children of Named and With re-encode their fields with the new key
+	s.setConfig(s.config().clone())
+}
+
+// Pseudonym constructs a field with a keyed HMAC of value instead of the value so entries about the same identifier
+// can be correlated without logging it. The key is set with Logger.SetPseudonymKey; the value is masked until a key is
+// set. Only top level, context and With fields are pseudonymized, in nested objects the value is masked.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Pseudonym(key string, value string) Field {
+	return Field(zap.Stringer(key, pseudonymValue{value: value}))
+}
+
+// DerivePseudonym returns the pseudonym a Pseudonym field of value is written as under the key with id keyID. It is
+// used by logtool pseudonym to search logs for an identifier.
+func DerivePseudonym(keyID string, key []byte, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return keyID + ":" + hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+type pseudonymValue struct {
+	value string
+}
+
+func (p pseudonymValue) String() string {
+	return defaultRedactionMask
+}
+
+// pseudonymize returns fields with Pseudonym fields replaced by their pseudonyms. It only copies fields if one is
+// found.
+func pseudonymize(pk *pseudonymKey, fields []zapcore.Field) []zapcore.Field {
+	if pk == nil {
+		return fields
+	}
+	var pseudonymized []zapcore.Field
+	for i := range fields {
+		p, isPseudonym := fields[i].Interface.(pseudonymValue)
+		if !isPseudonym {
+			continue
+		}
+		if pseudonymized == nil {
+			pseudonymized = make([]zapcore.Field, len(fields))
+			copy(pseudonymized, fields)
+		}
+		pseudonymized[i] = zap.String(fields[i].Key, DerivePseudonym(pk.id, pk.key, p.value))
+	}
+	if pseudonymized == nil {
+		return fields
+	}
+	return pseudonymized
+}
@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// recordWriter receives log entries as a map of fields instead of encoded bytes. It is implemented by sinks that speak
+// a structured wire protocol (Fluentd Forward, GELF).
+type recordWriter interface {
+	writeRecord(ent zapcore.Entry, fields map[string]interface{}) error
+	Sync() error
+}
+
+// recordCore is a zapcore.Core that hands each entry and its fields to a recordWriter.
+type recordCore struct {
+	zapcore.LevelEnabler
+	fields []zapcore.Field
+	out    recordWriter
+}
+
+func newRecordCore(out recordWriter, level zapcore.LevelEnabler) zapcore.Core {
+	return &recordCore{
+		LevelEnabler: level,
+		out:          out,
+	}
+}
+
+func (c *recordCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &recordCore{
+		LevelEnabler: c.LevelEnabler,
+		fields:       make([]zapcore.Field, 0, len(c.fields)+len(fields)),
+		out:          c.out,
+	}
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return clone
+}
+
+func (c *recordCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *recordCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for i := range c.fields {
+		c.fields[i].AddTo(enc)
+	}
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+	return c.out.writeRecord(ent, enc.Fields)
+}
+
+func (c *recordCore) Sync() error {
+	return c.out.Sync()
+}
@@ -0,0 +1,283 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const defaultRedactionMask = "[REDACTED]"
+
+// RedactAction is what redaction does with a secret value.
+type RedactAction int
+
+//goland:noinspection GoUnusedConst
+const (
+	// RedactMask replaces the value with RedactionOptions.Mask.
+	RedactMask RedactAction = iota
+	// RedactHash replaces the value with a truncated HMAC-SHA256 under RedactionOptions.HashKey so equal values can be
+	// correlated.
+	RedactHash
+	// RedactDrop removes the field. Value pattern matches in messages are masked.
+	RedactDrop
+)
+
+// RedactionOptions configures the redaction applied by every LogInstance, see SetRedaction.
+type RedactionOptions struct {
+	// KeyPatterns are matched case-insensitively against field keys, including keys of nested objects, with
+	// path.Match wildcards, e.g. "password" or "*_token".
+	KeyPatterns []string
+	// ValuePatterns are replaced in messages and string values.
+	ValuePatterns []*regexp.Regexp
+	// Action defaults to RedactMask.
+	Action RedactAction
+	// Mask defaults to "[REDACTED]".
+	Mask string
+	// HashKey is the HMAC key of RedactHash. Values like card numbers have too little entropy for a plain hash, the
+	// key must stay secret. If empty SetRedaction generates a random key, so hashes only correlate within the process.
+	// Set the same key in every process to correlate hashes across processes and restarts.
+	HashKey []byte
+}
+
+// DefaultRedactionOptions returns key patterns for common credentials and value patterns for email addresses, card
+// numbers and JWTs.
+//
+//goland:noinspection GoUnusedExportedFunction
+func DefaultRedactionOptions() RedactionOptions {
+	return RedactionOptions{
+		KeyPatterns: []string{
+			"password", "passwd", "*_password",
+			"secret", "*_secret",
+			"token", "*_token",
+			"authorization", "cookie", "set-cookie",
+			"api_key", "apikey", "*_api_key",
+		},
+		ValuePatterns: []*regexp.Regexp{
+			regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+			regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+			regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		},
+	}
+}
+
+// SetRedaction makes every LogInstance redact messages and fields, including fields of contexts, Logger.With
+// children and nested objects, before they are encoded. Any and Reflect values are redacted after a JSON round trip.
+// example: logger.Instance().SetRedaction(logger.DefaultRedactionOptions())
+func (s *Logger) SetRedaction(redactionOpts RedactionOptions) {
+	if redactionOpts.Mask == "" {
+		redactionOpts.Mask = defaultRedactionMask
+	}
+	keyPatterns := make([]string, len(redactionOpts.KeyPatterns))
+	for i, pattern := range redactionOpts.KeyPatterns {
+		keyPatterns[i] = strings.ToLower(pattern)
+	}
+	redactionOpts.KeyPatterns = keyPatterns
+	hashKey := make([]byte, len(redactionOpts.HashKey))
+	copy(hashKey, redactionOpts.HashKey)
+	if len(hashKey) == 0 {
+		hashKey = make([]byte, sha256.Size)
+		if _, err := rand.Read(hashKey); err != nil {
+			panic(fmt.Sprintf("logger: generating redaction hash key: %v", err))
+		}
+	}
+	redactionOpts.HashKey = hashKey
+	s.storeRedaction(&redactor{opts: redactionOpts})
+}
+
+// DisableRedaction removes the redaction set by SetRedaction. Secret fields are still masked.
+func (s *Logger) DisableRedaction() {
+	s.storeRedaction(nil)
+}
+
+// storeRedaction recompiles the config so children of Named and With re-encode their fields with the new redaction.
+func (s *Logger) storeRedaction(r *redactor) {
+	s.cfgMutex.Lock()
+	defer s.cfgMutex.Unlock()
+	s.redaction.Store(r)
+	s.setConfig(s.config().clone())
+}
+
+// Secret constructs a field whose value is never printed. It is masked even without SetRedaction, or hashed or
+// dropped according to the RedactionOptions. Only top level, context and With fields are hashed or dropped, a Secret
+// inside an object, like Dict or Object, is always masked.
+//
+//goland:noinspection GoUnusedExportedFunction
+func Secret(key string, val interface{}) Field {
+	return Field(zap.Stringer(key, secretValue{value: val}))
+}
+
+type secretValue struct {
+	value interface{}
+}
+
+func (s secretValue) String() string {
+	return defaultRedactionMask
+}
+
+type redactor struct {
+	opts RedactionOptions
+}
+
+func (r *redactor) keyMatches(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range r.opts.KeyPatterns {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString replaces the value pattern matches in s.
+func (r *redactor) redactString(s string) string {
+	for _, pattern := range r.opts.ValuePatterns {
+		s = pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if r.opts.Action == RedactHash {
+				return r.hash(match)
+			}
+			return r.opts.Mask
+		})
+	}
+	return s
+}
+
+// replacement returns what a secret value is replaced with, or false if it is dropped.
+func (r *redactor) replacement(value interface{}) (string, bool) {
+	switch r.opts.Action {
+	case RedactHash:
+		return r.hash(fmt.Sprint(value)), true
+	case RedactDrop:
+		return "", false
+	default:
+		return r.opts.Mask, true
+	}
+}
+
+// hash returns the RedactHash replacement of value.
+func (r *redactor) hash(value string) string {
+	mac := hmac.New(sha256.New, r.opts.HashKey)
+	mac.Write([]byte(value))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// redactFields returns a copy of fields with secrets replaced.
+func (r *redactor) redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, 0, len(fields))
+	for _, field := range fields {
+		if field, keep := r.redactField(field); keep {
+			redacted = append(redacted, field)
+		}
+	}
+	return redacted
+}
+
+func (r *redactor) redactField(field zapcore.Field) (zapcore.Field, bool) {
+	if secret, isSecret := field.Interface.(secretValue); isSecret {
+		replacement, keep := r.replacement(secret.value)
+		return zap.String(field.Key, replacement), keep
+	}
+	if field.Type != zapcore.SkipType && field.Key != "" && r.keyMatches(field.Key) {
+		replacement, keep := r.replacement(fieldValue(field))
+		return zap.String(field.Key, replacement), keep
+	}
+	return walkField(r, field), true
+}
+
+func (r *redactor) transformLeaf(value interface{}) interface{} {
+	if s, isString := value.(string); isString {
+		return r.redactString(s)
+	}
+	return value
+}
+
+// transformEntry replaces the values of nested keys matching the key patterns.
+func (r *redactor) transformEntry(key string, value interface{}) (interface{}, bool, bool) {
+	if !r.keyMatches(key) {
+		return nil, false, false
+	}
+	replacement, keep := r.replacement(value)
+	return replacement, keep, true
+}
+
+// mapObject adds the entries of a map in key order.
+type mapObject map[string]interface{}
+
+func (m mapObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := addValue(enc, key, m[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldValue returns the value of a field for hashing.
+func fieldValue(field zapcore.Field) interface{} {
+	enc := zapcore.NewMapObjectEncoder()
+	field.AddTo(enc)
+	return enc.Fields[field.Key]
+}
+
+// jsonRoundTrip converts value to the maps, slices and scalars of its JSON form so nested keys can be redacted.
+func jsonRoundTrip(value interface{}) interface{} {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%+v", value)
+	}
+	var decoded interface{}
+	if err = json.Unmarshal(encoded, &decoded); err != nil {
+		return string(encoded)
+	}
+	return decoded
+}
+
+// redactCore applies the redaction set by SetRedaction and the key set by SetPseudonymKey to the messages and fields
+// of a LogInstance.
+type redactCore struct {
+	zapcore.Core
+	redaction    *atomic.Pointer[redactor]
+	pseudonymKey *atomic.Pointer[pseudonymKey]
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	fields = pseudonymize(c.pseudonymKey.Load(), fields)
+	if r := c.redaction.Load(); r != nil {
+		fields = r.redactFields(fields)
+	}
+	return &redactCore{
+		Core:         c.Core.With(fields),
+		redaction:    c.redaction,
+		pseudonymKey: c.pseudonymKey,
+	}
+}
+
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	fields = pseudonymize(c.pseudonymKey.Load(), fields)
+	if r := c.redaction.Load(); r != nil {
+		ent.Message = r.redactString(ent.Message)
+		fields = r.redactFields(fields)
+	}
+	return c.Core.Write(ent, fields)
+}
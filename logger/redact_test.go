@@ -0,0 +1,65 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestRedactHashIsKeyed(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	hashKey := []byte("redaction key")
+	s.SetRedaction(RedactionOptions{KeyPatterns: []string{"password"}, Action: RedactHash, HashKey: hashKey})
+	s.Info("entry", String("password", "hunter2"), Secret("card", "4111111111111111"))
+
+	mac := hmac.New(sha256.New, hashKey)
+	mac.Write([]byte("hunter2"))
+	want := "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8])
+	entry := lastEntry(t, buf)
+	if entry["password"] != want {
+		t.Fatalf("password = %v, want %s", entry["password"], want)
+	}
+	unkeyed := sha256.Sum256([]byte("4111111111111111"))
+	if card := entry["card"].(string); card == "sha256:"+hex.EncodeToString(unkeyed[:8]) || len(card) != len(want) {
+		t.Fatalf("card = %s, want a keyed hash", card)
+	}
+
+	s.SetRedaction(RedactionOptions{KeyPatterns: []string{"password"}, Action: RedactHash, HashKey: []byte("other key")})
+	s.Info("entry", String("password", "hunter2"))
+	if rotated := lastEntry(t, buf)["password"]; rotated == want {
+		t.Fatal("hash did not change with the key")
+	}
+}
+
+func TestRedactHashRandomKeyPerSetRedaction(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	redactionOpts := RedactionOptions{KeyPatterns: []string{"password"}, Action: RedactHash}
+	s.SetRedaction(redactionOpts)
+	s.Info("entry", String("password", "hunter2"))
+	s.Info("entry", String("password", "hunter2"))
+	entries := buf.entries(t)
+	if entries[0]["password"] != entries[1]["password"] {
+		t.Fatal("equal values hashed differently under one key")
+	}
+
+	s.SetRedaction(redactionOpts)
+	s.Info("entry", String("password", "hunter2"))
+	if lastEntry(t, buf)["password"] == entries[0]["password"] {
+		t.Fatal("a generated key was reused")
+	}
+}
+
+func TestSecretInObjectIsMasked(t *testing.T) {
+	s, buf := newTestLogger(t, InfoLevel)
+	s.SetRedaction(RedactionOptions{Action: RedactDrop})
+	s.Info("entry", Secret("token", "abc"), Dict("user", Secret("token", "abc")))
+
+	entry := lastEntry(t, buf)
+	if _, found := entry["token"]; found {
+		t.Fatalf("top level Secret not dropped: %v", entry)
+	}
+	if user := entry["user"].(map[string]interface{}); user["token"] != defaultRedactionMask {
+		t.Fatalf("nested Secret = %v, want %s", user["token"], defaultRedactionMask)
+	}
+}
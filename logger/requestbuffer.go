@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"sync"
+)
+
+const (
+	defaultRequestBufferMaxEntries = 1000
+	defaultRequestBufferMaxBytes   = 1024 * 1024
+)
+
+// RequestBufferOptions limits the memory held by a request buffer. When a limit is reached the oldest entries are
+// dropped and the number dropped is added to the entry that flushes the buffer as "request_buffer_dropped".
+type RequestBufferOptions struct {
+	// MaxEntries defaults to 1000.
+	MaxEntries int
+	// MaxBytes is an estimate of the size of the messages and fields held. Defaults to 1MB.
+	MaxBytes int
+}
+
+// WithRequestBuffer starts a request buffer scope with the default limits, see WithRequestBufferOptions.
+// example:
+//
+//	ctx, end := logger.WithRequestBuffer(ctx)
+//	defer end()
+func WithRequestBuffer(ctx context.Context) (context.Context, func()) {
+	return WithRequestBufferOptions(ctx, RequestBufferOptions{})
+}
+
+// WithRequestBufferOptions starts a request buffer scope. Trace, Debug and Info entries of every ContextLogger of
+// the returned context are held in memory instead of written. If an entry at Error or above is logged in the scope
+// the held entries are written first, in order and with their original timestamps, and later entries are written
+// directly. Calling the returned function ends the scope and drops the held entries.
+func WithRequestBufferOptions(ctx context.Context, bufferOpts RequestBufferOptions) (context.Context, func()) {
+	if bufferOpts.MaxEntries <= 0 {
+		bufferOpts.MaxEntries = defaultRequestBufferMaxEntries
+	}
+	if bufferOpts.MaxBytes <= 0 {
+		bufferOpts.MaxBytes = defaultRequestBufferMaxBytes
+	}
+	buffer := &requestBuffer{
+		opts: bufferOpts,
+	}
+	return context.WithValue(ctx, loggerRequestBufferContextKey, buffer), buffer.end
+}
+
+// bufferedEntry is an entry that passed the level check, held with its CheckedEntry so it is written to the cores
+// that accepted it without being checked, and sampled, again.
+type bufferedEntry struct {
+	ce     *zapcore.CheckedEntry
+	fields []zapcore.Field
+	size   int
+}
+
+type requestBuffer struct {
+	opts RequestBufferOptions
+
+	mutex   sync.Mutex // protects the fields below
+	entries []bufferedEntry
+	bytes   int
+	dropped int
+	// flushed is set when an entry at Error or above was logged, ended when the scope ended. Entries are written
+	// directly once either is set.
+	flushed bool
+	ended   bool
+}
+
+// write writes an entry that passed the level check, or holds it.
+func (b *requestBuffer) write(ce *zapcore.CheckedEntry, fields []zapcore.Field) {
+	b.mutex.Lock()
+	if b.flushed || b.ended {
+		b.mutex.Unlock()
+		ce.Write(fields...)
+		return
+	}
+	if ce.Level < zapcore.WarnLevel {
+		b.hold(bufferedEntry{
+			ce:     ce,
+			fields: fields,
+			size:   entrySize(ce.Entry, fields),
+		})
+		b.mutex.Unlock()
+		return
+	}
+	if ce.Level >= zapcore.ErrorLevel {
+		b.flushed = true
+		for _, held := range b.entries {
+			held.ce.Write(held.fields...)
+		}
+		if b.dropped > 0 {
+			fields = append(fields, zap.Int("request_buffer_dropped", b.dropped))
+		}
+		b.entries = nil
+	}
+	b.mutex.Unlock()
+	ce.Write(fields...)
+}
+
+// hold appends entry dropping the oldest entries over the limits. Callers must hold mutex.
+func (b *requestBuffer) hold(entry bufferedEntry) {
+	b.entries = append(b.entries, entry)
+	b.bytes += entry.size
+	var drop int
+	for len(b.entries)-drop > b.opts.MaxEntries || (b.bytes > b.opts.MaxBytes && drop < len(b.entries)) {
+		b.bytes -= b.entries[drop].size
+		drop++
+	}
+	if drop > 0 {
+		b.dropped += drop
+		b.entries = append(b.entries[:0], b.entries[drop:]...)
+	}
+}
+
+func (b *requestBuffer) end() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.ended = true
+	b.entries = nil
+}
+
+// entrySize estimates the memory held by an entry.
+func entrySize(ent zapcore.Entry, fields []zapcore.Field) (size int) {
+	size = len(ent.Message) + len(ent.Stack) + 64
+	for _, field := range fields {
+		size += len(field.Key) + len(field.String) + 32
+	}
+	return
+}
+
+func requestBufferOf(ctx context.Context) *requestBuffer {
+	buffer, _ := ctx.Value(loggerRequestBufferContextKey).(*requestBuffer)
+	return buffer
+}
@@ -0,0 +1,32 @@
+package logger
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRequestBufferFlushesWithoutResampling(t *testing.T) {
+	samplingOpts := WithSampling(SamplingOptions{Tick: time.Hour, First: 2})
+	s := NewLogger()
+	s.StartTask(samplingOpts)
+	t.Cleanup(s.StopTask)
+	buf := new(syncBuffer)
+	s.AddLogger("test", buf, DebugLevel, samplingOpts)
+
+	ctx, end := WithRequestBuffer(WithLogger(context.Background(), s))
+	defer end()
+	log := OfMust(ctx)
+	// both entries pass the sampler when logged, flushing them must not count them again
+	log.Debug("held")
+	log.Debug("held")
+	if lines := buf.lines(); len(lines) != 0 {
+		t.Fatalf("entries written before the flush: %q", lines)
+	}
+	log.Error("failed")
+
+	entries := buf.entries(t)
+	if len(entries) != 3 || entries[0]["msg"] != "held" || entries[1]["msg"] != "held" || entries[2]["msg"] != "failed" {
+		t.Fatalf("unexpected entries after the flush: %q", buf.lines())
+	}
+}
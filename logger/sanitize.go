@@ -0,0 +1,218 @@
+package logger
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const defaultTruncationMarker = "...[truncated]"
+
+// ansiEscape matches CSI sequences (colors, cursor movement) and OSC sequences (titles, hyperlinks).
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)`)
+
+// SanitizeOptions configures how a LogInstance cleans messages and fields before they are encoded. Zero values
+// disable each limit.
+type SanitizeOptions struct {
+	// EscapeControl replaces control characters in the message and string fields with Go escapes (\n, \x1b) so user
+	// input cannot forge log lines on encoders that write them unescaped like the debug console.
+	EscapeControl bool
+	// StripANSI removes ANSI escape sequences from the message and string fields.
+	StripANSI bool
+	// MaxMessageLength caps the message in bytes.
+	MaxMessageLength int
+	// MaxFieldLength caps string, byte string, Stringer and error fields in bytes, and the strings in objects and
+	// arrays.
+	MaxFieldLength int
+	// MaxBinaryLength caps Binary fields in bytes.
+	MaxBinaryLength int
+	// MaxEntryBytes caps the approximate encoded size of an entry. Fields past the cap are dropped and counted in a
+	// "truncated_fields" field.
+	MaxEntryBytes int
+	// TruncationMarker is appended to truncated strings. Defaults to "...[truncated]".
+	TruncationMarker string
+}
+
+// defaultConsoleSanitizeOptions is used by the debug console unless StartTask is called WithSanitization because the
+// console encoder writes messages unescaped.
+var defaultConsoleSanitizeOptions = SanitizeOptions{
+	EscapeControl: true,
+	StripANSI:     true,
+}
+
+// WithSanitization sets the SanitizeOptions of a LogInstance added with AddLogger (or one of the network sinks), or of
+// the built-in instances when passed to StartTask.
+// example: logger.Instance().AddLogger("audit", w, logger.InfoLevel, logger.WithSanitization(logger.SanitizeOptions{EscapeControl: true, MaxFieldLength: 4096}))
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithSanitization(sanitizeOptions SanitizeOptions) LoggingOption {
+	return func(o *Options) {
+		o.sanitizeOptions = sanitizeOptions
+		o.sanitizeEnabled = true
+	}
+}
+
+// sanitizeCore applies SanitizeOptions to the entries of a LogInstance.
+type sanitizeCore struct {
+	zapcore.Core
+	opts    SanitizeOptions
+	metrics *instanceMetrics
+	// sizer encodes single fields to measure them for MaxEntryBytes
+	sizer zapcore.Encoder
+}
+
+func newSanitizeCore(core zapcore.Core, sanitizeOpts SanitizeOptions, metrics *instanceMetrics) zapcore.Core {
+	if sanitizeOpts.TruncationMarker == "" {
+		sanitizeOpts.TruncationMarker = defaultTruncationMarker
+	}
+	return &sanitizeCore{
+		Core:    core,
+		opts:    sanitizeOpts,
+		metrics: metrics,
+		sizer:   zapcore.NewJSONEncoder(zapcore.EncoderConfig{}),
+	}
+}
+
+func (c *sanitizeCore) With(fields []zapcore.Field) zapcore.Core {
+	return &sanitizeCore{
+		Core:    c.Core.With(c.sanitizeFields(fields)),
+		opts:    c.opts,
+		metrics: c.metrics,
+		sizer:   c.sizer,
+	}
+}
+
+func (c *sanitizeCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *sanitizeCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = c.sanitizeString(ent.Message, c.opts.MaxMessageLength)
+	fields = c.sanitizeFields(fields)
+	if c.opts.MaxEntryBytes > 0 {
+		ent, fields = c.capEntry(ent, fields)
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// sanitizeFields returns a copy of fields with strings cleaned and capped, including the strings of nested objects
+// and arrays.
+func (c *sanitizeCore) sanitizeFields(fields []zapcore.Field) []zapcore.Field {
+	sanitized := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		sanitized[i] = walkField(c, field)
+	}
+	return sanitized
+}
+
+func (c *sanitizeCore) transformLeaf(value interface{}) interface{} {
+	switch val := value.(type) {
+	case string:
+		return c.sanitizeString(val, c.opts.MaxFieldLength)
+	case []byte:
+		if c.opts.MaxBinaryLength > 0 && len(val) > c.opts.MaxBinaryLength {
+			c.metrics.truncated.Inc()
+			return val[:c.opts.MaxBinaryLength]
+		}
+	}
+	return value
+}
+
+func (c *sanitizeCore) transformEntry(string, interface{}) (interface{}, bool, bool) {
+	return nil, false, false
+}
+
+func (c *sanitizeCore) sanitizeString(s string, maxLength int) string {
+	if c.opts.StripANSI && strings.IndexByte(s, 0x1b) >= 0 {
+		s = ansiEscape.ReplaceAllString(s, "")
+	}
+	if c.opts.EscapeControl {
+		s = escapeControl(s)
+	}
+	if maxLength > 0 && len(s) > maxLength {
+		s = truncateString(s, maxLength) + c.opts.TruncationMarker
+		c.metrics.truncated.Inc()
+	}
+	return s
+}
+
+// capEntry drops the fields that do not fit in MaxEntryBytes after the message.
+func (c *sanitizeCore) capEntry(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	size := len(ent.Message)
+	if size > c.opts.MaxEntryBytes {
+		ent.Message = truncateString(ent.Message, c.opts.MaxEntryBytes) + c.opts.TruncationMarker
+		size = c.opts.MaxEntryBytes
+		c.metrics.truncated.Inc()
+	}
+	for i, field := range fields {
+		size += c.fieldSize(field)
+		if size > c.opts.MaxEntryBytes {
+			capped := make([]zapcore.Field, i, i+1)
+			copy(capped, fields[:i])
+			c.metrics.truncated.Inc()
+			return ent, append(capped, zap.Int("truncated_fields", len(fields)-i))
+		}
+	}
+	return ent, fields
+}
+
+// fieldSize returns the JSON encoded size of field.
+func (c *sanitizeCore) fieldSize(field zapcore.Field) int {
+	buf, err := c.sizer.EncodeEntry(zapcore.Entry{}, []zapcore.Field{field})
+	if err != nil {
+		return 0
+	}
+	defer buf.Free()
+	return buf.Len()
+}
+
+// escapeControl replaces control characters with Go escapes.
+func escapeControl(s string) string {
+	i := strings.IndexFunc(s, isControl)
+	if i < 0 {
+		return s
+	}
+	var sb strings.Builder
+	sb.Grow(len(s) + 8)
+	sb.WriteString(s[:i])
+	for _, r := range s[i:] {
+		if !isControl(r) {
+			sb.WriteRune(r)
+			continue
+		}
+		switch r {
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if r < 0x100 {
+				_, _ = fmt.Fprintf(&sb, `\x%02x`, r)
+			} else {
+				_, _ = fmt.Fprintf(&sb, `\u%04x`, r)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// isControl reports C0 and C1 control characters, DEL, and the Unicode line and paragraph separators.
+func isControl(r rune) bool {
+	return r < 0x20 || (r >= 0x7f && r <= 0x9f) || r == '\u2028' || r == '\u2029'
+}
+
+// truncateString cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncateString(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newSanitizeTestLogger(t *testing.T, sanitizeOptions SanitizeOptions) (*Logger, *syncBuffer) {
+	t.Helper()
+	s, _ := newTestLogger(t, InfoLevel)
+	buf := new(syncBuffer)
+	s.AddLogger("sanitized", buf, InfoLevel, WithSanitization(sanitizeOptions))
+	return s, buf
+}
+
+func TestSanitizeErrorField(t *testing.T) {
+	s, buf := newSanitizeTestLogger(t, SanitizeOptions{EscapeControl: true, MaxFieldLength: 10})
+	s.Info("entry", Error(errors.New("call\nsite error that is long")))
+
+	entry := lastEntry(t, buf)
+	if want := `call\nsite` + defaultTruncationMarker; entry["error"] != want {
+		t.Fatalf("error = %q, want %q", entry["error"], want)
+	}
+}
+
+func TestSanitizeNestedFields(t *testing.T) {
+	s, buf := newSanitizeTestLogger(t, SanitizeOptions{EscapeControl: true, MaxFieldLength: 10})
+	s.Info("entry",
+		Dict("dict", String("value", "ab\ncd")),
+		Group("", String("inline", "a\nb")),
+		Any("reflected", map[string]interface{}{"value": strings.Repeat("x", 20)}),
+		Strings("array", []string{"c\nd"}),
+	)
+
+	entry := lastEntry(t, buf)
+	if got := entry["dict"].(map[string]interface{})["value"]; got != `ab\ncd` {
+		t.Errorf("dict value = %q", got)
+	}
+	if got := entry["inline"]; got != `a\nb` {
+		t.Errorf("inline = %q", got)
+	}
+	if got := entry["reflected"].(map[string]interface{})["value"]; got != strings.Repeat("x", 10)+defaultTruncationMarker {
+		t.Errorf("reflected value = %q", got)
+	}
+	if got := entry["array"].([]interface{})[0]; got != `c\nd` {
+		t.Errorf("array element = %q", got)
+	}
+}
+
+func TestSanitizeKeepsErrorVerbose(t *testing.T) {
+	s, buf := newSanitizeTestLogger(t, SanitizeOptions{EscapeControl: true})
+	s.Info("entry", Any("cause", verboseError{msg: "with details"}))
+
+	entry := lastEntry(t, buf)
+	if entry["cause"] != "with details" {
+		t.Fatalf("cause = %q", entry["cause"])
+	}
+	if verbose := entry["causeVerbose"]; verbose != `with details\nverbose details` {
+		t.Fatalf("causeVerbose = %q, want the escaped details", verbose)
+	}
+}
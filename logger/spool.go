@@ -0,0 +1,516 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSpoolMaxBytes       = 100 * 1024 * 1024
+	defaultSpoolSegmentBytes   = 8 * 1024 * 1024
+	defaultSpoolReplayInterval = 5 * time.Second
+
+	spoolSegmentExt = ".seg"
+	spoolStateFile  = "replay.state"
+	// record frame: payload length (4) + payload + crc32 of payload (4)
+	spoolRecordOverhead = 8
+)
+
+var errSpoolCorrupt = errors.New("corrupt spool record")
+
+// SpoolOptions configures the on-disk spool used by network sinks while the remote side is unreachable.
+type SpoolOptions struct {
+	// Dir is the spool directory. Each LogInstance spools to a sub-directory named after its key.
+	Dir string
+	// MaxBytes caps the total size of the spool. When exceeded the oldest segments are dropped, so the spool can only
+	// go over it by the last entry written. Defaults to 100MB.
+	MaxBytes int64
+	// SegmentBytes is the size at which a new segment file is started. Defaults to 8MB, and is capped at MaxBytes.
+	SegmentBytes int64
+	// ReplayInterval is how often delivery of spooled entries is retried. Defaults to 5 seconds.
+	ReplayInterval time.Duration
+}
+
+// WithSpool makes a network backed LogInstance (AddFluentForwardLogger, AddGelfLogger, AddHTTPLogger) write entries
+// to segment files on disk when they cannot be delivered, and replay them in order once the remote side recovers.
+// Spooled entries survive process restarts.
+// example: logger.Instance().AddHTTPLogger("collector", httpOpts, logger.InfoLevel, logger.WithSpool(logger.SpoolOptions{Dir: "/var/spool/myapp"}))
+//
+//goland:noinspection GoUnusedExportedFunction
+func WithSpool(spoolOptions SpoolOptions) LoggingOption {
+	return func(o *Options) {
+		o.spoolOptions = spoolOptions
+		o.spoolEnabled = true
+	}
+}
+
+// newSinkBatchWriter creates the batchWriter for a network sink. If the instance was added WithSpool, sends go
+// through a spoolSender.
+func newSinkBatchWriter(key string, sender batchSender, batchOpts batchOptions, addLoggerOpts *Options, onError func(err error)) *batchWriter {
+	if addLoggerOpts.spoolEnabled {
+		spooled, err := newSpoolSender(filepath.Join(addLoggerOpts.spoolOptions.Dir, key), addLoggerOpts.spoolOptions, sender, batchOpts.maxEntries, onError)
+		if err != nil {
+			onError(fmt.Errorf("spool disabled: %w", err))
+		} else {
+			sender = spooled
+		}
+	}
+	return newBatchWriter(sender, batchOpts, onError)
+}
+
+// spoolSender delivers batches with the wrapped batchSender. When a send fails the batch is appended to a diskSpool
+// and every later batch is spooled behind it until a background replay has emptied the spool, so delivery order is
+// preserved.
+type spoolSender struct {
+	inner        batchSender
+	spool        *diskSpool
+	replayBatch  int
+	onError      func(err error)
+	sendMutex    sync.Mutex // serializes sends to inner and spool appends so order is kept
+	replayTicker *time.Ticker
+	done         chan struct{}
+	closeOnce    sync.Once
+}
+
+func newSpoolSender(dir string, spoolOpts SpoolOptions, inner batchSender, replayBatch int, onError func(err error)) (*spoolSender, error) {
+	if spoolOpts.ReplayInterval <= 0 {
+		spoolOpts.ReplayInterval = defaultSpoolReplayInterval
+	}
+	if replayBatch <= 0 {
+		replayBatch = defaultBatchMaxEntries
+	}
+	spool, err := openDiskSpool(dir, spoolOpts)
+	if err != nil {
+		return nil, err
+	}
+	p := &spoolSender{
+		inner:        inner,
+		spool:        spool,
+		replayBatch:  replayBatch,
+		onError:      onError,
+		replayTicker: time.NewTicker(spoolOpts.ReplayInterval),
+		done:         make(chan struct{}),
+	}
+	go p.run()
+	return p, nil
+}
+
+func (p *spoolSender) send(batch [][]byte) error {
+	p.sendMutex.Lock()
+	defer p.sendMutex.Unlock()
+
+	if p.spool.empty() {
+		err := p.inner.send(batch)
+		if err == nil || errors.As(err, new(permanentSendError)) {
+			return err
+		}
+		p.onError(fmt.Errorf("spooling entries to disk: %w", err))
+	}
+	if err := p.spool.append(batch); err != nil {
+		return err
+	}
+	p.reportDropped()
+	return nil
+}
+
+func (p *spoolSender) run() {
+	// replay anything left from a previous process right away
+	p.replay()
+	for {
+		select {
+		case <-p.replayTicker.C:
+			p.replay()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Close stops the replay goroutine and closes the spool and the wrapped sender if it is an io.Closer. Entries still
+// spooled are replayed by the next process. It is called by the batchWriter after the last send.
+func (p *spoolSender) Close() (err error) {
+	p.closeOnce.Do(func() {
+		p.replayTicker.Stop()
+		close(p.done)
+
+		p.sendMutex.Lock()
+		defer p.sendMutex.Unlock()
+		err = p.spool.close()
+		if closer, isCloser := p.inner.(io.Closer); isCloser {
+			if closeErr := closer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	})
+	return
+}
+
+// replay sends spooled entries in order until the spool is empty or a send fails.
+func (p *spoolSender) replay() {
+	p.sendMutex.Lock()
+	defer p.sendMutex.Unlock()
+
+	for !p.spool.empty() {
+		batch, next, err := p.spool.read(p.replayBatch)
+		if err != nil {
+			p.onError(fmt.Errorf("reading spool: %w", err))
+			return
+		}
+		if len(batch) > 0 {
+			if err = p.inner.send(batch); err != nil {
+				if !errors.As(err, new(permanentSendError)) {
+					// remote is still down, try again on the next tick
+					return
+				}
+				p.onError(fmt.Errorf("dropping %d spooled entries: %w", len(batch), err))
+			}
+		}
+		if err = p.spool.commit(next); err != nil {
+			p.onError(fmt.Errorf("committing spool position: %w", err))
+			return
+		}
+	}
+}
+
+func (p *spoolSender) reportDropped() {
+	if droppedBytes := p.spool.takeDroppedBytes(); droppedBytes > 0 {
+		p.onError(fmt.Errorf("spool full dropped %d bytes of oldest entries", droppedBytes))
+	}
+}
+
+// spoolPosition is a read position in the spool.
+type spoolPosition struct {
+	seq    uint64
+	offset int64
+}
+
+// diskSpool is an append only log of records split into numbered segment files. The read position is persisted in
+// a state file after every committed replay so replay resumes where it left off after a crash.
+type diskSpool struct {
+	dir  string
+	opts SpoolOptions
+
+	mutex        sync.Mutex // protects everything below
+	segments     []uint64   // sequence numbers of segment files, oldest first
+	sizes        map[uint64]int64
+	totalBytes   int64
+	active       *os.File // the newest segment, open for append
+	readPos      spoolPosition
+	droppedBytes int64
+}
+
+func openDiskSpool(dir string, opts SpoolOptions) (spool *diskSpool, err error) {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaultSpoolMaxBytes
+	}
+	if opts.SegmentBytes <= 0 {
+		opts.SegmentBytes = defaultSpoolSegmentBytes
+	}
+	// segments are dropped whole, a single segment larger than MaxBytes could never be dropped
+	if opts.SegmentBytes > opts.MaxBytes {
+		opts.SegmentBytes = opts.MaxBytes
+	}
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return
+	}
+
+	spool = &diskSpool{
+		dir:   dir,
+		opts:  opts,
+		sizes: make(map[uint64]int64),
+	}
+
+	var dirEntries []os.DirEntry
+	if dirEntries, err = os.ReadDir(dir); err != nil {
+		return
+	}
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if !strings.HasSuffix(name, spoolSegmentExt) {
+			continue
+		}
+		seq, parseErr := strconv.ParseUint(strings.TrimSuffix(name, spoolSegmentExt), 10, 64)
+		if parseErr != nil {
+			continue
+		}
+		spool.segments = append(spool.segments, seq)
+	}
+	sort.Slice(spool.segments, func(i, j int) bool { return spool.segments[i] < spool.segments[j] })
+
+	for _, seq := range spool.segments {
+		var size int64
+		if size, err = spool.validSize(seq); err != nil {
+			return
+		}
+		spool.sizes[seq] = size
+		spool.totalBytes += size
+	}
+
+	spool.readPos = spool.loadState()
+	// discard segments that were fully replayed before a crash
+	for len(spool.segments) > 0 && spool.segments[0] < spool.readPos.seq {
+		if err = spool.removeOldest(); err != nil {
+			return
+		}
+	}
+	if len(spool.segments) > 0 && spool.readPos.seq < spool.segments[0] {
+		spool.readPos = spoolPosition{seq: spool.segments[0]}
+	}
+	return
+}
+
+// close closes the active segment.
+func (d *diskSpool) close() (err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.active != nil {
+		err = d.active.Close()
+		d.active = nil
+	}
+	return
+}
+
+func (d *diskSpool) segmentPath(seq uint64) string {
+	return filepath.Join(d.dir, fmt.Sprintf("%020d%s", seq, spoolSegmentExt))
+}
+
+// validSize returns the size of the segment up to the last complete record, truncating a partial record left by a
+// crash in the middle of an append.
+func (d *diskSpool) validSize(seq uint64) (size int64, err error) {
+	var f *os.File
+	if f, err = os.OpenFile(d.segmentPath(seq), os.O_RDWR, 0o600); err != nil {
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var info os.FileInfo
+	if info, err = f.Stat(); err != nil {
+		return
+	}
+	r := bufio.NewReader(f)
+	for {
+		payload, readErr := readSpoolRecord(r, info.Size()-size)
+		if readErr != nil {
+			break
+		}
+		size += int64(len(payload) + spoolRecordOverhead)
+	}
+
+	if info.Size() != size {
+		err = f.Truncate(size)
+	}
+	return
+}
+
+func (d *diskSpool) loadState() (pos spoolPosition) {
+	raw, err := os.ReadFile(filepath.Join(d.dir, spoolStateFile))
+	if err != nil {
+		return
+	}
+	_, _ = fmt.Sscanf(string(raw), "%d %d", &pos.seq, &pos.offset)
+	return
+}
+
+func (d *diskSpool) saveState() error {
+	tmpPath := filepath.Join(d.dir, spoolStateFile+".tmp")
+	if err := os.WriteFile(tmpPath, []byte(fmt.Sprintf("%d %d\n", d.readPos.seq, d.readPos.offset)), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filepath.Join(d.dir, spoolStateFile))
+}
+
+func (d *diskSpool) empty() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return len(d.segments) == 0
+}
+
+func (d *diskSpool) takeDroppedBytes() (droppedBytes int64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	droppedBytes = d.droppedBytes
+	d.droppedBytes = 0
+	return
+}
+
+// append writes entries to the newest segment, rolling to a new segment when it is full, and drops the oldest
+// segments while the spool is over MaxBytes.
+func (d *diskSpool) append(entries [][]byte) (err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, entry := range entries {
+		if d.active == nil || d.sizes[d.segments[len(d.segments)-1]] >= d.opts.SegmentBytes {
+			if err = d.roll(); err != nil {
+				return
+			}
+		}
+		seq := d.segments[len(d.segments)-1]
+		record := make([]byte, 0, len(entry)+spoolRecordOverhead)
+		record = binary.BigEndian.AppendUint32(record, uint32(len(entry)))
+		record = append(record, entry...)
+		record = binary.BigEndian.AppendUint32(record, crc32.ChecksumIEEE(entry))
+		if _, err = d.active.Write(record); err != nil {
+			return
+		}
+		d.sizes[seq] += int64(len(record))
+		d.totalBytes += int64(len(record))
+	}
+	if err = d.active.Sync(); err != nil {
+		return
+	}
+
+	for d.totalBytes > d.opts.MaxBytes && len(d.segments) > 1 {
+		d.droppedBytes += d.sizes[d.segments[0]]
+		if err = d.removeOldest(); err != nil {
+			return
+		}
+		d.readPos = spoolPosition{seq: d.segments[0]}
+	}
+	if err = d.saveState(); err != nil {
+		return
+	}
+	return
+}
+
+// roll closes the active segment and starts a new one.
+func (d *diskSpool) roll() (err error) {
+	if d.active != nil {
+		if err = d.active.Close(); err != nil {
+			return
+		}
+		d.active = nil
+	}
+
+	var seq uint64
+	if len(d.segments) > 0 {
+		seq = d.segments[len(d.segments)-1]
+		if d.sizes[seq] >= d.opts.SegmentBytes {
+			seq++
+		}
+	} else {
+		seq = d.readPos.seq + 1
+	}
+
+	if d.active, err = os.OpenFile(d.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+		return
+	}
+	if len(d.segments) == 0 || d.segments[len(d.segments)-1] != seq {
+		d.segments = append(d.segments, seq)
+	}
+	if len(d.segments) == 1 && d.readPos.seq != seq {
+		d.readPos = spoolPosition{seq: seq}
+	}
+	return
+}
+
+func (d *diskSpool) removeOldest() error {
+	seq := d.segments[0]
+	if d.active != nil && len(d.segments) == 1 {
+		_ = d.active.Close()
+		d.active = nil
+	}
+	d.segments = d.segments[1:]
+	d.totalBytes -= d.sizes[seq]
+	delete(d.sizes, seq)
+	if err := os.Remove(d.segmentPath(seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// read returns up to max records starting at the read position and the position after them.
+func (d *diskSpool) read(max int) (entries [][]byte, next spoolPosition, err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	next = d.readPos
+	if len(d.segments) == 0 {
+		return
+	}
+
+	var f *os.File
+	if f, err = os.Open(d.segmentPath(next.seq)); err != nil {
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if _, err = f.Seek(next.offset, io.SeekStart); err != nil {
+		return
+	}
+
+	size := d.sizes[next.seq]
+	r := bufio.NewReader(f)
+	for len(entries) < max && next.offset < size {
+		payload, readErr := readSpoolRecord(r, size-next.offset)
+		if readErr != nil {
+			// treat a corrupt record as the end of the segment
+			next.offset = size
+			break
+		}
+		entries = append(entries, payload)
+		next.offset += int64(len(payload) + spoolRecordOverhead)
+	}
+	return
+}
+
+// commit advances the read position to next after the records up to it were delivered, removing segments that
+// have been fully replayed.
+func (d *diskSpool) commit(next spoolPosition) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if len(d.segments) == 0 || next.seq != d.segments[0] {
+		// the segment was dropped while the batch was being sent
+		return nil
+	}
+	d.readPos = next
+	if next.offset >= d.sizes[next.seq] {
+		if err := d.removeOldest(); err != nil {
+			return err
+		}
+		if len(d.segments) > 0 {
+			d.readPos = spoolPosition{seq: d.segments[0]}
+		}
+	}
+	return d.saveState()
+}
+
+// readSpoolRecord reads the next record of at most remaining bytes. A length that does not fit is corruption, it is
+// checked before the payload is allocated so a torn or corrupt header cannot allocate gigabytes.
+func readSpoolRecord(r *bufio.Reader, remaining int64) (payload []byte, err error) {
+	header := make([]byte, 4)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+	length := int64(binary.BigEndian.Uint32(header))
+	if length+spoolRecordOverhead > remaining {
+		err = errSpoolCorrupt
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+	if binary.BigEndian.Uint32(header) != crc32.ChecksumIEEE(payload) {
+		err = errSpoolCorrupt
+	}
+	return
+}
@@ -0,0 +1,216 @@
+package logger
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSender records delivered entries. It fails every send while down, and after accepting limit batches when
+// limit is positive.
+type fakeSender struct {
+	mutex     sync.Mutex
+	down      bool
+	limit     int
+	batches   int
+	delivered []string
+}
+
+func (f *fakeSender) send(batch [][]byte) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.down || (f.limit > 0 && f.batches >= f.limit) {
+		return errors.New("remote unavailable")
+	}
+	f.batches++
+	for _, entry := range batch {
+		f.delivered = append(f.delivered, string(entry))
+	}
+	return nil
+}
+
+func (f *fakeSender) entries() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string(nil), f.delivered...)
+}
+
+func (f *fakeSender) waitFor(t *testing.T, want ...string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !reflect.DeepEqual(f.entries(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("delivered %q, want %q", f.entries(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// openTestSpool opens a spoolSender on dir that only replays on open, and closes it when the test ends.
+func openTestSpool(t *testing.T, dir string, inner batchSender) *spoolSender {
+	t.Helper()
+	p, err := newSpoolSender(dir, SpoolOptions{ReplayInterval: time.Hour}, inner, 1, func(error) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = p.Close()
+	})
+	return p
+}
+
+func sendEntries(t *testing.T, p *spoolSender, entries ...string) {
+	t.Helper()
+	for _, entry := range entries {
+		if err := p.send([][]byte{[]byte(entry)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSpoolReplaysAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	// the first process never closes its spool, as if it crashed
+	crashed, err := newSpoolSender(dir, SpoolOptions{ReplayInterval: time.Hour}, &fakeSender{down: true}, 1, func(error) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = crashed.Close()
+	})
+	sendEntries(t, crashed, "a", "b", "c")
+
+	remote := &fakeSender{}
+	p := openTestSpool(t, dir, remote)
+	remote.waitFor(t, "a", "b", "c")
+	sendEntries(t, p, "d")
+	remote.waitFor(t, "a", "b", "c", "d")
+}
+
+func TestSpoolResumesFromCommittedPosition(t *testing.T) {
+	dir := t.TempDir()
+	down := openTestSpool(t, dir, &fakeSender{down: true})
+	sendEntries(t, down, "a", "b", "c")
+	_ = down.Close()
+
+	// the remote accepts one replayed batch, then goes away again
+	flaky := &fakeSender{limit: 1}
+	partial := openTestSpool(t, dir, flaky)
+	flaky.waitFor(t, "a")
+	_ = partial.Close()
+
+	remote := &fakeSender{}
+	openTestSpool(t, dir, remote)
+	remote.waitFor(t, "b", "c")
+}
+
+func TestSpoolTruncatesTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	down := openTestSpool(t, dir, &fakeSender{down: true})
+	sendEntries(t, down, "a", "b")
+	segmentPath := down.spool.segmentPath(down.spool.segments[0])
+	_ = down.Close()
+
+	// a crash in the middle of an append leaves a header and part of the payload
+	torn := binary.BigEndian.AppendUint32(nil, 100)
+	torn = append(torn, "par"...)
+	f, err := os.OpenFile(segmentPath, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write(torn); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	reopened := openTestSpool(t, dir, &fakeSender{down: true})
+	sendEntries(t, reopened, "c")
+	_ = reopened.Close()
+
+	remote := &fakeSender{}
+	openTestSpool(t, dir, remote)
+	remote.waitFor(t, "a", "b", "c")
+}
+
+func TestSpoolRejectsCorruptLength(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := openDiskSpool(dir, SpoolOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := binary.BigEndian.AppendUint32(nil, 0xfffffff0)
+	corrupt = append(corrupt, "garbage"...)
+	if err = os.WriteFile(spool.segmentPath(1), corrupt, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if spool, err = openDiskSpool(dir, SpoolOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64*1024*1024 {
+		t.Errorf("opening the spool allocated %d bytes", allocated)
+	}
+	if spool.sizes[1] != 0 {
+		t.Errorf("corrupt segment has size %d, want 0", spool.sizes[1])
+	}
+	if info, statErr := os.Stat(spool.segmentPath(1)); statErr != nil || info.Size() != 0 {
+		t.Errorf("corrupt segment was not truncated: %v %v", info, statErr)
+	}
+}
+
+func TestSpoolCloseStopsReplay(t *testing.T) {
+	before := runtime.NumGoroutine()
+	p, err := newSpoolSender(t.TempDir(), SpoolOptions{ReplayInterval: time.Millisecond}, &fakeSender{down: true}, 1, func(error) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendEntries(t, p, "a")
+	if err = p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	waitForGoroutines(t, before)
+}
+
+func TestSpoolCapsSegmentBytesAtMaxBytes(t *testing.T) {
+	const maxBytes = 100
+	entry := []byte("0123456789")
+	recordBytes := int64(len(entry) + spoolRecordOverhead)
+	spool, err := openDiskSpool(t.TempDir(), SpoolOptions{MaxBytes: maxBytes, SegmentBytes: 10 * maxBytes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = spool.close()
+	})
+	for i := 0; i < 50; i++ {
+		if err = spool.append([][]byte{entry}); err != nil {
+			t.Fatal(err)
+		}
+		if spool.totalBytes > maxBytes+recordBytes {
+			t.Fatalf("spool grew to %d bytes after %d entries, MaxBytes is %d", spool.totalBytes, i+1, maxBytes)
+		}
+	}
+	if spool.takeDroppedBytes() == 0 {
+		t.Fatal("no oldest entries dropped")
+	}
+
+	var onDisk int64
+	for _, seq := range spool.segments {
+		info, statErr := os.Stat(spool.segmentPath(seq))
+		if statErr != nil {
+			t.Fatal(statErr)
+		}
+		onDisk += info.Size()
+	}
+	if onDisk != spool.totalBytes {
+		t.Fatalf("segments hold %d bytes, spool counts %d", onDisk, spool.totalBytes)
+	}
+}
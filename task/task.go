@@ -77,6 +77,7 @@ func LogErrorStructIgnoreCancel(ctx context.Context, taskName string, msg string
 //goland:noinspection GoUnusedExportedFunction
 func HandlePanic(taskName string) {
 	if err := recover(); err != nil {
+		// logging at Error also dumps the flight recorder
 		LogErrorStruct(taskName, "panic occurred", logger.Any("err", err), logger.String("stacktrace", string(debug.Stack())))
 	}
 }